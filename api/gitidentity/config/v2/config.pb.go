@@ -124,11 +124,17 @@ func (x *VersionEntity) GetVersion() string {
 }
 
 type Config struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Version       string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"` // for this object must equal to "v2"
-	List          []*Identity            `protobuf:"bytes,2,rep,name=list,proto3" json:"list,omitempty"`       // list of targets
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Version            string                 `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`                                                                             // for this object must equal to "v2" or "v3"
+	List               []*Identity            `protobuf:"bytes,2,rep,name=list,proto3" json:"list,omitempty"`                                                                                   // list of targets
+	Defaults           map[string]string      `protobuf:"bytes,3,rep,name=defaults,proto3" json:"defaults,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // git config values applied with every identity (identity values take precedence, empty identity value disables default)
+	Include            []string               `protobuf:"bytes,4,rep,name=include,proto3" json:"include,omitempty"`                                                                             // paths or glob patterns of other configuration files to load (relative paths are resolved against directory of this file)
+	FirstMatchWins     bool                   `protobuf:"varint,5,opt,name=first_match_wins,json=firstMatchWins,proto3" json:"first_match_wins,omitempty"`                                      // when several identities with the same priority match automatically, apply the first one instead of asking (enabled when set in any loaded file)
+	Profiles           map[string]*Profile    `protobuf:"bytes,6,rep,name=profiles,proto3" json:"profiles,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // named profiles, identities and default values of the active profile are used together with the ones above, requires configuration version v3
+	Rules              map[string]*MatchList  `protobuf:"bytes,7,rep,name=rules,proto3" json:"rules,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`       // named match rules shared by identities of all loaded files (rule of nearer layer takes precedence), referenced by name in auto apply rules, requires configuration version v3
+	TrustedDirectories []string               `protobuf:"bytes,8,rep,name=trusted_directories,json=trustedDirectories,proto3" json:"trusted_directories,omitempty"`                             // directories (including subdirectories) in which directory configuration files are trusted, only honored in user and system configuration
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *Config) Reset() {
@@ -175,18 +181,124 @@ func (x *Config) GetList() []*Identity {
 	return nil
 }
 
-type Identity struct {
+func (x *Config) GetDefaults() map[string]string {
+	if x != nil {
+		return x.Defaults
+	}
+	return nil
+}
+
+func (x *Config) GetInclude() []string {
+	if x != nil {
+		return x.Include
+	}
+	return nil
+}
+
+func (x *Config) GetFirstMatchWins() bool {
+	if x != nil {
+		return x.FirstMatchWins
+	}
+	return false
+}
+
+func (x *Config) GetProfiles() map[string]*Profile {
+	if x != nil {
+		return x.Profiles
+	}
+	return nil
+}
+
+func (x *Config) GetRules() map[string]*MatchList {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
+func (x *Config) GetTrustedDirectories() []string {
+	if x != nil {
+		return x.TrustedDirectories
+	}
+	return nil
+}
+
+type Profile struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Identifier    string                 `protobuf:"bytes,1,opt,name=identifier,proto3" json:"identifier,omitempty"`                                                                   // identity identifier
-	Values        map[string]string      `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // other git config values
-	AutoApplyWhen []*MatchList           `protobuf:"bytes,100,rep,name=auto_apply_when,json=autoApplyWhen,proto3" json:"auto_apply_when,omitempty"`                                    // logical disjunction of logical conjunctions of match rules for identity auto application
+	List          []*Identity            `protobuf:"bytes,1,rep,name=list,proto3" json:"list,omitempty"`                                                                                   // identities available only when profile is active
+	Defaults      map[string]string      `protobuf:"bytes,2,rep,name=defaults,proto3" json:"defaults,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // default values applied when profile is active (take precedence over configuration default values)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Profile) Reset() {
+	*x = Profile{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Profile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Profile) ProtoMessage() {}
+
+func (x *Profile) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Profile.ProtoReflect.Descriptor instead.
+func (*Profile) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Profile) GetList() []*Identity {
+	if x != nil {
+		return x.List
+	}
+	return nil
+}
+
+func (x *Profile) GetDefaults() map[string]string {
+	if x != nil {
+		return x.Defaults
+	}
+	return nil
+}
+
+type Identity struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Identifier    string                  `protobuf:"bytes,1,opt,name=identifier,proto3" json:"identifier,omitempty"`                                                                                                // identity identifier
+	Values        map[string]string       `protobuf:"bytes,2,rep,name=values,proto3" json:"values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`                              // other git config values
+	Extends       []string                `protobuf:"bytes,3,rep,name=extends,proto3" json:"extends,omitempty"`                                                                                                      // identifiers of base identities, which values and rules are merged in order (identity own values take precedence), requires configuration version v3
+	Abstract      bool                    `protobuf:"varint,4,opt,name=abstract,proto3" json:"abstract,omitempty"`                                                                                                   // abstract identities are never offered nor automatically applied, they only serve as base for other identities, requires configuration version v3
+	ExpandValues  bool                    `protobuf:"varint,5,opt,name=expand_values,json=expandValues,proto3" json:"expand_values,omitempty"`                                                                       // expand templates in values when applying: leading ~, ${ENV} environment variables (undefined ones are an error, bare $ENV is kept as is), ${repo.dir} repository top-level directory, ${remote.host}, ${remote.owner} and ${remote.repo} of origin (or first) remote
+	ValuesFrom    map[string]*ValueSource `protobuf:"bytes,6,rep,name=values_from,json=valuesFrom,proto3" json:"values_from,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`    // git config values resolved by running command or shell script when identity is applied (take precedence over values)
+	Priority      int32                   `protobuf:"varint,7,opt,name=priority,proto3" json:"priority,omitempty"`                                                                                                   // auto apply priority, identities with higher priority are matched first, identities with equal priority are matched in configuration order
+	UnsetValues   []string                `protobuf:"bytes,8,rep,name=unset_values,json=unsetValues,proto3" json:"unset_values,omitempty"`                                                                           // git config keys overridden with empty value in repository config, so that values inherited from other scopes (e.g. global commit.gpgsign or credential.helper) are not in effect
+	MultiValues   map[string]*ValueList   `protobuf:"bytes,9,rep,name=multi_values,json=multiValues,proto3" json:"multi_values,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // multi-valued git config values (e.g. url.<base>.insteadOf, credential.helper or include.path), all values are added in order
+	Description   string                  `protobuf:"bytes,10,opt,name=description,proto3" json:"description,omitempty"`                                                                                             // human readable description shown when selecting identity
+	Tags          []string                `protobuf:"bytes,11,rep,name=tags,proto3" json:"tags,omitempty"`                                                                                                           // tags grouping identities (e.g. by client), candidates can be restricted to tagged identities with --tag option
+	Disabled      bool                    `protobuf:"varint,12,opt,name=disabled,proto3" json:"disabled,omitempty"`                                                                                                  // disabled identities are never offered nor automatically applied
+	ExpiresAt     string                  `protobuf:"bytes,13,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`                                                                                // RFC 3339 time after which identity is no longer offered nor automatically applied
+	Profile       string                  `protobuf:"bytes,14,opt,name=profile,proto3" json:"profile,omitempty"`                                                                                                     // profile active when identity was applied, recorded in applied identity for traceability (filled in automatically, must not be set in configuration)
+	AutoApplyWhen []*MatchList            `protobuf:"bytes,100,rep,name=auto_apply_when,json=autoApplyWhen,proto3" json:"auto_apply_when,omitempty"`                                                                 // logical disjunction of logical conjunctions of match rules for identity auto application
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Identity) Reset() {
 	*x = Identity{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[2]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -198,7 +310,7 @@ func (x *Identity) String() string {
 func (*Identity) ProtoMessage() {}
 
 func (x *Identity) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[2]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -211,7 +323,7 @@ func (x *Identity) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Identity.ProtoReflect.Descriptor instead.
 func (*Identity) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{2}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Identity) GetIdentifier() string {
@@ -228,6 +340,90 @@ func (x *Identity) GetValues() map[string]string {
 	return nil
 }
 
+func (x *Identity) GetExtends() []string {
+	if x != nil {
+		return x.Extends
+	}
+	return nil
+}
+
+func (x *Identity) GetAbstract() bool {
+	if x != nil {
+		return x.Abstract
+	}
+	return false
+}
+
+func (x *Identity) GetExpandValues() bool {
+	if x != nil {
+		return x.ExpandValues
+	}
+	return false
+}
+
+func (x *Identity) GetValuesFrom() map[string]*ValueSource {
+	if x != nil {
+		return x.ValuesFrom
+	}
+	return nil
+}
+
+func (x *Identity) GetPriority() int32 {
+	if x != nil {
+		return x.Priority
+	}
+	return 0
+}
+
+func (x *Identity) GetUnsetValues() []string {
+	if x != nil {
+		return x.UnsetValues
+	}
+	return nil
+}
+
+func (x *Identity) GetMultiValues() map[string]*ValueList {
+	if x != nil {
+		return x.MultiValues
+	}
+	return nil
+}
+
+func (x *Identity) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Identity) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Identity) GetDisabled() bool {
+	if x != nil {
+		return x.Disabled
+	}
+	return false
+}
+
+func (x *Identity) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *Identity) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
 func (x *Identity) GetAutoApplyWhen() []*MatchList {
 	if x != nil {
 		return x.AutoApplyWhen
@@ -235,16 +431,143 @@ func (x *Identity) GetAutoApplyWhen() []*MatchList {
 	return nil
 }
 
+type ValueList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []string               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"` // values of git config key in order
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueList) Reset() {
+	*x = ValueList{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueList) ProtoMessage() {}
+
+func (x *ValueList) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueList.ProtoReflect.Descriptor instead.
+func (*ValueList) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ValueList) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type ValueSource struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Source:
+	//
+	//	*ValueSource_Command
+	//	*ValueSource_ShellScript
+	Source        isValueSource_Source `protobuf_oneof:"source"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueSource) Reset() {
+	*x = ValueSource{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueSource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueSource) ProtoMessage() {}
+
+func (x *ValueSource) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueSource.ProtoReflect.Descriptor instead.
+func (*ValueSource) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ValueSource) GetSource() isValueSource_Source {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *ValueSource) GetCommand() *MatchCommand {
+	if x != nil {
+		if x, ok := x.Source.(*ValueSource_Command); ok {
+			return x.Command
+		}
+	}
+	return nil
+}
+
+func (x *ValueSource) GetShellScript() *MatchShellScript {
+	if x != nil {
+		if x, ok := x.Source.(*ValueSource_ShellScript); ok {
+			return x.ShellScript
+		}
+	}
+	return nil
+}
+
+type isValueSource_Source interface {
+	isValueSource_Source()
+}
+
+type ValueSource_Command struct {
+	Command *MatchCommand `protobuf:"bytes,1,opt,name=command,proto3,oneof"` // trimmed command output is used as value, output condition (when set) must be satisfied
+}
+
+type ValueSource_ShellScript struct {
+	ShellScript *MatchShellScript `protobuf:"bytes,2,opt,name=shell_script,json=shellScript,proto3,oneof"` // trimmed script output is used as value, output condition (when set) must be satisfied
+}
+
+func (*ValueSource_Command) isValueSource_Source() {}
+
+func (*ValueSource_ShellScript) isValueSource_Source() {}
+
 type MatchList struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Match         []*Match               `protobuf:"bytes,1,rep,name=match,proto3" json:"match,omitempty"` // logical conjunction of match rules
+	Rules         []string               `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"` // names of shared rules (see configuration rules), which match rules are included in the conjunction
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *MatchList) Reset() {
 	*x = MatchList{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[3]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -256,7 +579,7 @@ func (x *MatchList) String() string {
 func (*MatchList) ProtoMessage() {}
 
 func (x *MatchList) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[3]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -269,7 +592,7 @@ func (x *MatchList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MatchList.ProtoReflect.Descriptor instead.
 func (*MatchList) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{3}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *MatchList) GetMatch() []*Match {
@@ -279,6 +602,13 @@ func (x *MatchList) GetMatch() []*Match {
 	return nil
 }
 
+func (x *MatchList) GetRules() []string {
+	if x != nil {
+		return x.Rules
+	}
+	return nil
+}
+
 type Match struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to Subject:
@@ -287,6 +617,9 @@ type Match struct {
 	//	*Match_Remote
 	//	*Match_Command
 	//	*Match_ShellScript
+	//	*Match_Location
+	//	*Match_Expression
+	//	*Match_Repository
 	Subject       isMatch_Subject `protobuf_oneof:"subject"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -294,7 +627,7 @@ type Match struct {
 
 func (x *Match) Reset() {
 	*x = Match{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[4]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -306,7 +639,7 @@ func (x *Match) String() string {
 func (*Match) ProtoMessage() {}
 
 func (x *Match) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[4]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -319,7 +652,7 @@ func (x *Match) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Match.ProtoReflect.Descriptor instead.
 func (*Match) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{4}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Match) GetSubject() isMatch_Subject {
@@ -365,6 +698,33 @@ func (x *Match) GetShellScript() *MatchShellScript {
 	return nil
 }
 
+func (x *Match) GetLocation() *MatchLocation {
+	if x != nil {
+		if x, ok := x.Subject.(*Match_Location); ok {
+			return x.Location
+		}
+	}
+	return nil
+}
+
+func (x *Match) GetExpression() *MatchExpression {
+	if x != nil {
+		if x, ok := x.Subject.(*Match_Expression); ok {
+			return x.Expression
+		}
+	}
+	return nil
+}
+
+func (x *Match) GetRepository() *MatchRepository {
+	if x != nil {
+		if x, ok := x.Subject.(*Match_Repository); ok {
+			return x.Repository
+		}
+	}
+	return nil
+}
+
 type isMatch_Subject interface {
 	isMatch_Subject()
 }
@@ -385,6 +745,18 @@ type Match_ShellScript struct {
 	ShellScript *MatchShellScript `protobuf:"bytes,4,opt,name=shell_script,json=shellScript,proto3,oneof"` // match rules on shell script output
 }
 
+type Match_Location struct {
+	Location *MatchLocation `protobuf:"bytes,5,opt,name=location,proto3,oneof"` // match rules on repository location
+}
+
+type Match_Expression struct {
+	Expression *MatchExpression `protobuf:"bytes,6,opt,name=expression,proto3,oneof"` // match rule as CEL expression
+}
+
+type Match_Repository struct {
+	Repository *MatchRepository `protobuf:"bytes,7,opt,name=repository,proto3,oneof"` // match rules on repository location of Git remote, independent of remote url syntax
+}
+
 func (*Match_Env) isMatch_Subject() {}
 
 func (*Match_Remote) isMatch_Subject() {}
@@ -393,6 +765,12 @@ func (*Match_Command) isMatch_Subject() {}
 
 func (*Match_ShellScript) isMatch_Subject() {}
 
+func (*Match_Location) isMatch_Subject() {}
+
+func (*Match_Expression) isMatch_Subject() {}
+
+func (*Match_Repository) isMatch_Subject() {}
+
 type MatchEnv struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"` // name of the environment variable
@@ -403,7 +781,7 @@ type MatchEnv struct {
 
 func (x *MatchEnv) Reset() {
 	*x = MatchEnv{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[5]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -415,7 +793,7 @@ func (x *MatchEnv) String() string {
 func (*MatchEnv) ProtoMessage() {}
 
 func (x *MatchEnv) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[5]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -428,7 +806,7 @@ func (x *MatchEnv) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MatchEnv.ProtoReflect.Descriptor instead.
 func (*MatchEnv) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{5}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *MatchEnv) GetName() string {
@@ -455,7 +833,7 @@ type MatchRemote struct {
 
 func (x *MatchRemote) Reset() {
 	*x = MatchRemote{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[6]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -467,7 +845,7 @@ func (x *MatchRemote) String() string {
 func (*MatchRemote) ProtoMessage() {}
 
 func (x *MatchRemote) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[6]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -480,7 +858,7 @@ func (x *MatchRemote) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MatchRemote.ProtoReflect.Descriptor instead.
 func (*MatchRemote) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{6}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *MatchRemote) GetName() *Condition {
@@ -497,6 +875,178 @@ func (x *MatchRemote) GetUrl() *Condition {
 	return nil
 }
 
+type MatchRepository struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RemoteName    *Condition             `protobuf:"bytes,1,opt,name=remote_name,json=remoteName,proto3" json:"remote_name,omitempty"` // conditions to match remote name
+	Scheme        *Condition             `protobuf:"bytes,2,opt,name=scheme,proto3" json:"scheme,omitempty"`                           // conditions to match scheme of remote url (ssh for scp-like syntax, file for local paths)
+	Host          *Condition             `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`                               // conditions to match lower-cased host of remote url
+	Port          *Condition             `protobuf:"bytes,4,opt,name=port,proto3" json:"port,omitempty"`                               // conditions to match port of remote url (default port of scheme, when not given explicitly)
+	Owner         *Condition             `protobuf:"bytes,5,opt,name=owner,proto3" json:"owner,omitempty"`                             // conditions to match owner of repository, all path segments except the last one (e.g. group/subgroup for nested GitLab groups)
+	Name          *Condition             `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`                               // conditions to match repository name, last path segment without .git suffix
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchRepository) Reset() {
+	*x = MatchRepository{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchRepository) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchRepository) ProtoMessage() {}
+
+func (x *MatchRepository) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchRepository.ProtoReflect.Descriptor instead.
+func (*MatchRepository) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MatchRepository) GetRemoteName() *Condition {
+	if x != nil {
+		return x.RemoteName
+	}
+	return nil
+}
+
+func (x *MatchRepository) GetScheme() *Condition {
+	if x != nil {
+		return x.Scheme
+	}
+	return nil
+}
+
+func (x *MatchRepository) GetHost() *Condition {
+	if x != nil {
+		return x.Host
+	}
+	return nil
+}
+
+func (x *MatchRepository) GetPort() *Condition {
+	if x != nil {
+		return x.Port
+	}
+	return nil
+}
+
+func (x *MatchRepository) GetOwner() *Condition {
+	if x != nil {
+		return x.Owner
+	}
+	return nil
+}
+
+func (x *MatchRepository) GetName() *Condition {
+	if x != nil {
+		return x.Name
+	}
+	return nil
+}
+
+type MatchLocation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          *Condition             `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"` // conditions to match absolute path of repository top-level directory
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchLocation) Reset() {
+	*x = MatchLocation{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchLocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchLocation) ProtoMessage() {}
+
+func (x *MatchLocation) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchLocation.ProtoReflect.Descriptor instead.
+func (*MatchLocation) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *MatchLocation) GetPath() *Condition {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+type MatchExpression struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Expression    string                 `protobuf:"bytes,1,opt,name=expression,proto3" json:"expression,omitempty"` // CEL expression evaluating to bool, with variables: remotes (list of maps with name and url keys), env (map of environment variables), path (repository top-level directory), branch (current branch) and os (operating system, e.g. linux)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchExpression) Reset() {
+	*x = MatchExpression{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchExpression) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchExpression) ProtoMessage() {}
+
+func (x *MatchExpression) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchExpression.ProtoReflect.Descriptor instead.
+func (*MatchExpression) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *MatchExpression) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
 type MatchCommand struct {
 	state                protoimpl.MessageState `protogen:"open.v1"`
 	Cmd                  string                 `protobuf:"bytes,1,opt,name=cmd,proto3" json:"cmd,omitempty"`                                                                      // path to command or command executable (if available in PATH)
@@ -509,7 +1059,7 @@ type MatchCommand struct {
 
 func (x *MatchCommand) Reset() {
 	*x = MatchCommand{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[7]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -521,7 +1071,7 @@ func (x *MatchCommand) String() string {
 func (*MatchCommand) ProtoMessage() {}
 
 func (x *MatchCommand) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[7]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -534,7 +1084,7 @@ func (x *MatchCommand) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MatchCommand.ProtoReflect.Descriptor instead.
 func (*MatchCommand) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{7}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *MatchCommand) GetCmd() string {
@@ -576,7 +1126,7 @@ type MatchShellScript struct {
 
 func (x *MatchShellScript) Reset() {
 	*x = MatchShellScript{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[8]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -588,7 +1138,7 @@ func (x *MatchShellScript) String() string {
 func (*MatchShellScript) ProtoMessage() {}
 
 func (x *MatchShellScript) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[8]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -601,7 +1151,7 @@ func (x *MatchShellScript) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MatchShellScript.ProtoReflect.Descriptor instead.
 func (*MatchShellScript) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{8}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *MatchShellScript) GetContent() string {
@@ -625,6 +1175,110 @@ func (x *MatchShellScript) GetAllowNonZeroExitCode() bool {
 	return false
 }
 
+type Requirements struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*ValueRequirement    `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"` // requirements that identity values must satisfy (all of them)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Requirements) Reset() {
+	*x = Requirements{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Requirements) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Requirements) ProtoMessage() {}
+
+func (x *Requirements) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Requirements.ProtoReflect.Descriptor instead.
+func (*Requirements) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Requirements) GetValues() []*ValueRequirement {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type ValueRequirement struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`       // git config key of the value
+	Value         *Condition             `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`   // condition that the value must satisfy, missing value is treated as empty string
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"` // optional human readable explanation of the requirement
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueRequirement) Reset() {
+	*x = ValueRequirement{}
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueRequirement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueRequirement) ProtoMessage() {}
+
+func (x *ValueRequirement) ProtoReflect() protoreflect.Message {
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueRequirement.ProtoReflect.Descriptor instead.
+func (*ValueRequirement) Descriptor() ([]byte, []int) {
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ValueRequirement) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *ValueRequirement) GetValue() *Condition {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *ValueRequirement) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 type Condition struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Mode          ConditionMode          `protobuf:"varint,1,opt,name=mode,proto3,enum=gitidentity.config.v2.ConditionMode" json:"mode,omitempty"` // mode of string comparison
@@ -636,7 +1290,7 @@ type Condition struct {
 
 func (x *Condition) Reset() {
 	*x = Condition{}
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[9]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -648,7 +1302,7 @@ func (x *Condition) String() string {
 func (*Condition) ProtoMessage() {}
 
 func (x *Condition) ProtoReflect() protoreflect.Message {
-	mi := &file_gitidentity_config_v2_config_proto_msgTypes[9]
+	mi := &file_gitidentity_config_v2_config_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -661,7 +1315,7 @@ func (x *Condition) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Condition.ProtoReflect.Descriptor instead.
 func (*Condition) Descriptor() ([]byte, []int) {
-	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{9}
+	return file_gitidentity_config_v2_config_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *Condition) GetMode() ConditionMode {
@@ -691,33 +1345,104 @@ const file_gitidentity_config_v2_config_proto_rawDesc = "" +
 	"\n" +
 	"\"gitidentity/config/v2/config.proto\x12\x15gitidentity.config.v2\")\n" +
 	"\rVersionEntity\x12\x18\n" +
-	"\aversion\x18\x01 \x01(\tR\aversion\"W\n" +
+	"\aversion\x18\x01 \x01(\tR\aversion\"\x94\x05\n" +
 	"\x06Config\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\tR\aversion\x123\n" +
-	"\x04list\x18\x02 \x03(\v2\x1f.gitidentity.config.v2.IdentityR\x04list\"\xf4\x01\n" +
+	"\x04list\x18\x02 \x03(\v2\x1f.gitidentity.config.v2.IdentityR\x04list\x12G\n" +
+	"\bdefaults\x18\x03 \x03(\v2+.gitidentity.config.v2.Config.DefaultsEntryR\bdefaults\x12\x18\n" +
+	"\ainclude\x18\x04 \x03(\tR\ainclude\x12(\n" +
+	"\x10first_match_wins\x18\x05 \x01(\bR\x0efirstMatchWins\x12G\n" +
+	"\bprofiles\x18\x06 \x03(\v2+.gitidentity.config.v2.Config.ProfilesEntryR\bprofiles\x12>\n" +
+	"\x05rules\x18\a \x03(\v2(.gitidentity.config.v2.Config.RulesEntryR\x05rules\x12/\n" +
+	"\x13trusted_directories\x18\b \x03(\tR\x12trustedDirectories\x1a;\n" +
+	"\rDefaultsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a[\n" +
+	"\rProfilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x124\n" +
+	"\x05value\x18\x02 \x01(\v2\x1e.gitidentity.config.v2.ProfileR\x05value:\x028\x01\x1aZ\n" +
+	"\n" +
+	"RulesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x126\n" +
+	"\x05value\x18\x02 \x01(\v2 .gitidentity.config.v2.MatchListR\x05value:\x028\x01\"\xc5\x01\n" +
+	"\aProfile\x123\n" +
+	"\x04list\x18\x01 \x03(\v2\x1f.gitidentity.config.v2.IdentityR\x04list\x12H\n" +
+	"\bdefaults\x18\x02 \x03(\v2,.gitidentity.config.v2.Profile.DefaultsEntryR\bdefaults\x1a;\n" +
+	"\rDefaultsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x85\a\n" +
 	"\bIdentity\x12\x1e\n" +
 	"\n" +
 	"identifier\x18\x01 \x01(\tR\n" +
 	"identifier\x12C\n" +
-	"\x06values\x18\x02 \x03(\v2+.gitidentity.config.v2.Identity.ValuesEntryR\x06values\x12H\n" +
+	"\x06values\x18\x02 \x03(\v2+.gitidentity.config.v2.Identity.ValuesEntryR\x06values\x12\x18\n" +
+	"\aextends\x18\x03 \x03(\tR\aextends\x12\x1a\n" +
+	"\babstract\x18\x04 \x01(\bR\babstract\x12#\n" +
+	"\rexpand_values\x18\x05 \x01(\bR\fexpandValues\x12P\n" +
+	"\vvalues_from\x18\x06 \x03(\v2/.gitidentity.config.v2.Identity.ValuesFromEntryR\n" +
+	"valuesFrom\x12\x1a\n" +
+	"\bpriority\x18\a \x01(\x05R\bpriority\x12!\n" +
+	"\funset_values\x18\b \x03(\tR\vunsetValues\x12S\n" +
+	"\fmulti_values\x18\t \x03(\v20.gitidentity.config.v2.Identity.MultiValuesEntryR\vmultiValues\x12 \n" +
+	"\vdescription\x18\n" +
+	" \x01(\tR\vdescription\x12\x12\n" +
+	"\x04tags\x18\v \x03(\tR\x04tags\x12\x1a\n" +
+	"\bdisabled\x18\f \x01(\bR\bdisabled\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\r \x01(\tR\texpiresAt\x12\x18\n" +
+	"\aprofile\x18\x0e \x01(\tR\aprofile\x12H\n" +
 	"\x0fauto_apply_when\x18d \x03(\v2 .gitidentity.config.v2.MatchListR\rautoApplyWhen\x1a9\n" +
 	"\vValuesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"?\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1aa\n" +
+	"\x0fValuesFromEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x128\n" +
+	"\x05value\x18\x02 \x01(\v2\".gitidentity.config.v2.ValueSourceR\x05value:\x028\x01\x1a`\n" +
+	"\x10MultiValuesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x126\n" +
+	"\x05value\x18\x02 \x01(\v2 .gitidentity.config.v2.ValueListR\x05value:\x028\x01\"#\n" +
+	"\tValueList\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\tR\x06values\"\xa6\x01\n" +
+	"\vValueSource\x12?\n" +
+	"\acommand\x18\x01 \x01(\v2#.gitidentity.config.v2.MatchCommandH\x00R\acommand\x12L\n" +
+	"\fshell_script\x18\x02 \x01(\v2'.gitidentity.config.v2.MatchShellScriptH\x00R\vshellScriptB\b\n" +
+	"\x06source\"U\n" +
 	"\tMatchList\x122\n" +
-	"\x05match\x18\x01 \x03(\v2\x1c.gitidentity.config.v2.MatchR\x05match\"\x94\x02\n" +
+	"\x05match\x18\x01 \x03(\v2\x1c.gitidentity.config.v2.MatchR\x05match\x12\x14\n" +
+	"\x05rules\x18\x02 \x03(\tR\x05rules\"\xec\x03\n" +
 	"\x05Match\x123\n" +
 	"\x03env\x18\x01 \x01(\v2\x1f.gitidentity.config.v2.MatchEnvH\x00R\x03env\x12<\n" +
 	"\x06remote\x18\x02 \x01(\v2\".gitidentity.config.v2.MatchRemoteH\x00R\x06remote\x12?\n" +
 	"\acommand\x18\x03 \x01(\v2#.gitidentity.config.v2.MatchCommandH\x00R\acommand\x12L\n" +
-	"\fshell_script\x18\x04 \x01(\v2'.gitidentity.config.v2.MatchShellScriptH\x00R\vshellScriptB\t\n" +
+	"\fshell_script\x18\x04 \x01(\v2'.gitidentity.config.v2.MatchShellScriptH\x00R\vshellScript\x12B\n" +
+	"\blocation\x18\x05 \x01(\v2$.gitidentity.config.v2.MatchLocationH\x00R\blocation\x12H\n" +
+	"\n" +
+	"expression\x18\x06 \x01(\v2&.gitidentity.config.v2.MatchExpressionH\x00R\n" +
+	"expression\x12H\n" +
+	"\n" +
+	"repository\x18\a \x01(\v2&.gitidentity.config.v2.MatchRepositoryH\x00R\n" +
+	"repositoryB\t\n" +
 	"\asubject\"P\n" +
 	"\bMatchEnv\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x120\n" +
 	"\x02to\x18\x02 \x01(\v2 .gitidentity.config.v2.ConditionR\x02to\"w\n" +
 	"\vMatchRemote\x124\n" +
 	"\x04name\x18\x01 \x01(\v2 .gitidentity.config.v2.ConditionR\x04name\x122\n" +
-	"\x03url\x18\x02 \x01(\v2 .gitidentity.config.v2.ConditionR\x03url\"\xa6\x01\n" +
+	"\x03url\x18\x02 \x01(\v2 .gitidentity.config.v2.ConditionR\x03url\"\xe8\x02\n" +
+	"\x0fMatchRepository\x12A\n" +
+	"\vremote_name\x18\x01 \x01(\v2 .gitidentity.config.v2.ConditionR\n" +
+	"remoteName\x128\n" +
+	"\x06scheme\x18\x02 \x01(\v2 .gitidentity.config.v2.ConditionR\x06scheme\x124\n" +
+	"\x04host\x18\x03 \x01(\v2 .gitidentity.config.v2.ConditionR\x04host\x124\n" +
+	"\x04port\x18\x04 \x01(\v2 .gitidentity.config.v2.ConditionR\x04port\x126\n" +
+	"\x05owner\x18\x05 \x01(\v2 .gitidentity.config.v2.ConditionR\x05owner\x124\n" +
+	"\x04name\x18\x06 \x01(\v2 .gitidentity.config.v2.ConditionR\x04name\"E\n" +
+	"\rMatchLocation\x124\n" +
+	"\x04path\x18\x01 \x01(\v2 .gitidentity.config.v2.ConditionR\x04path\"1\n" +
+	"\x0fMatchExpression\x12\x1e\n" +
+	"\n" +
+	"expression\x18\x01 \x01(\tR\n" +
+	"expression\"\xa6\x01\n" +
 	"\fMatchCommand\x12\x10\n" +
 	"\x03cmd\x18\x01 \x01(\tR\x03cmd\x12\x12\n" +
 	"\x04args\x18\x02 \x03(\tR\x04args\x128\n" +
@@ -726,7 +1451,13 @@ const file_gitidentity_config_v2_config_proto_rawDesc = "" +
 	"\x10MatchShellScript\x12\x18\n" +
 	"\acontent\x18\x01 \x01(\tR\acontent\x128\n" +
 	"\x06output\x18\x02 \x01(\v2 .gitidentity.config.v2.ConditionR\x06output\x126\n" +
-	"\x18allow_non_zero_exit_code\x18\x03 \x01(\bR\x14allowNonZeroExitCode\"s\n" +
+	"\x18allow_non_zero_exit_code\x18\x03 \x01(\bR\x14allowNonZeroExitCode\"O\n" +
+	"\fRequirements\x12?\n" +
+	"\x06values\x18\x01 \x03(\v2'.gitidentity.config.v2.ValueRequirementR\x06values\"t\n" +
+	"\x10ValueRequirement\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x126\n" +
+	"\x05value\x18\x02 \x01(\v2 .gitidentity.config.v2.ConditionR\x05value\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"s\n" +
 	"\tCondition\x128\n" +
 	"\x04mode\x18\x01 \x01(\x0e2$.gitidentity.config.v2.ConditionModeR\x04mode\x12\x16\n" +
 	"\x06negate\x18\x02 \x01(\bR\x06negate\x12\x14\n" +
@@ -753,41 +1484,80 @@ func file_gitidentity_config_v2_config_proto_rawDescGZIP() []byte {
 }
 
 var file_gitidentity_config_v2_config_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_gitidentity_config_v2_config_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_gitidentity_config_v2_config_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_gitidentity_config_v2_config_proto_goTypes = []any{
 	(ConditionMode)(0),       // 0: gitidentity.config.v2.ConditionMode
 	(*VersionEntity)(nil),    // 1: gitidentity.config.v2.VersionEntity
 	(*Config)(nil),           // 2: gitidentity.config.v2.Config
-	(*Identity)(nil),         // 3: gitidentity.config.v2.Identity
-	(*MatchList)(nil),        // 4: gitidentity.config.v2.MatchList
-	(*Match)(nil),            // 5: gitidentity.config.v2.Match
-	(*MatchEnv)(nil),         // 6: gitidentity.config.v2.MatchEnv
-	(*MatchRemote)(nil),      // 7: gitidentity.config.v2.MatchRemote
-	(*MatchCommand)(nil),     // 8: gitidentity.config.v2.MatchCommand
-	(*MatchShellScript)(nil), // 9: gitidentity.config.v2.MatchShellScript
-	(*Condition)(nil),        // 10: gitidentity.config.v2.Condition
-	nil,                      // 11: gitidentity.config.v2.Identity.ValuesEntry
+	(*Profile)(nil),          // 3: gitidentity.config.v2.Profile
+	(*Identity)(nil),         // 4: gitidentity.config.v2.Identity
+	(*ValueList)(nil),        // 5: gitidentity.config.v2.ValueList
+	(*ValueSource)(nil),      // 6: gitidentity.config.v2.ValueSource
+	(*MatchList)(nil),        // 7: gitidentity.config.v2.MatchList
+	(*Match)(nil),            // 8: gitidentity.config.v2.Match
+	(*MatchEnv)(nil),         // 9: gitidentity.config.v2.MatchEnv
+	(*MatchRemote)(nil),      // 10: gitidentity.config.v2.MatchRemote
+	(*MatchRepository)(nil),  // 11: gitidentity.config.v2.MatchRepository
+	(*MatchLocation)(nil),    // 12: gitidentity.config.v2.MatchLocation
+	(*MatchExpression)(nil),  // 13: gitidentity.config.v2.MatchExpression
+	(*MatchCommand)(nil),     // 14: gitidentity.config.v2.MatchCommand
+	(*MatchShellScript)(nil), // 15: gitidentity.config.v2.MatchShellScript
+	(*Requirements)(nil),     // 16: gitidentity.config.v2.Requirements
+	(*ValueRequirement)(nil), // 17: gitidentity.config.v2.ValueRequirement
+	(*Condition)(nil),        // 18: gitidentity.config.v2.Condition
+	nil,                      // 19: gitidentity.config.v2.Config.DefaultsEntry
+	nil,                      // 20: gitidentity.config.v2.Config.ProfilesEntry
+	nil,                      // 21: gitidentity.config.v2.Config.RulesEntry
+	nil,                      // 22: gitidentity.config.v2.Profile.DefaultsEntry
+	nil,                      // 23: gitidentity.config.v2.Identity.ValuesEntry
+	nil,                      // 24: gitidentity.config.v2.Identity.ValuesFromEntry
+	nil,                      // 25: gitidentity.config.v2.Identity.MultiValuesEntry
 }
 var file_gitidentity_config_v2_config_proto_depIdxs = []int32{
-	3,  // 0: gitidentity.config.v2.Config.list:type_name -> gitidentity.config.v2.Identity
-	11, // 1: gitidentity.config.v2.Identity.values:type_name -> gitidentity.config.v2.Identity.ValuesEntry
-	4,  // 2: gitidentity.config.v2.Identity.auto_apply_when:type_name -> gitidentity.config.v2.MatchList
-	5,  // 3: gitidentity.config.v2.MatchList.match:type_name -> gitidentity.config.v2.Match
-	6,  // 4: gitidentity.config.v2.Match.env:type_name -> gitidentity.config.v2.MatchEnv
-	7,  // 5: gitidentity.config.v2.Match.remote:type_name -> gitidentity.config.v2.MatchRemote
-	8,  // 6: gitidentity.config.v2.Match.command:type_name -> gitidentity.config.v2.MatchCommand
-	9,  // 7: gitidentity.config.v2.Match.shell_script:type_name -> gitidentity.config.v2.MatchShellScript
-	10, // 8: gitidentity.config.v2.MatchEnv.to:type_name -> gitidentity.config.v2.Condition
-	10, // 9: gitidentity.config.v2.MatchRemote.name:type_name -> gitidentity.config.v2.Condition
-	10, // 10: gitidentity.config.v2.MatchRemote.url:type_name -> gitidentity.config.v2.Condition
-	10, // 11: gitidentity.config.v2.MatchCommand.output:type_name -> gitidentity.config.v2.Condition
-	10, // 12: gitidentity.config.v2.MatchShellScript.output:type_name -> gitidentity.config.v2.Condition
-	0,  // 13: gitidentity.config.v2.Condition.mode:type_name -> gitidentity.config.v2.ConditionMode
-	14, // [14:14] is the sub-list for method output_type
-	14, // [14:14] is the sub-list for method input_type
-	14, // [14:14] is the sub-list for extension type_name
-	14, // [14:14] is the sub-list for extension extendee
-	0,  // [0:14] is the sub-list for field type_name
+	4,  // 0: gitidentity.config.v2.Config.list:type_name -> gitidentity.config.v2.Identity
+	19, // 1: gitidentity.config.v2.Config.defaults:type_name -> gitidentity.config.v2.Config.DefaultsEntry
+	20, // 2: gitidentity.config.v2.Config.profiles:type_name -> gitidentity.config.v2.Config.ProfilesEntry
+	21, // 3: gitidentity.config.v2.Config.rules:type_name -> gitidentity.config.v2.Config.RulesEntry
+	4,  // 4: gitidentity.config.v2.Profile.list:type_name -> gitidentity.config.v2.Identity
+	22, // 5: gitidentity.config.v2.Profile.defaults:type_name -> gitidentity.config.v2.Profile.DefaultsEntry
+	23, // 6: gitidentity.config.v2.Identity.values:type_name -> gitidentity.config.v2.Identity.ValuesEntry
+	24, // 7: gitidentity.config.v2.Identity.values_from:type_name -> gitidentity.config.v2.Identity.ValuesFromEntry
+	25, // 8: gitidentity.config.v2.Identity.multi_values:type_name -> gitidentity.config.v2.Identity.MultiValuesEntry
+	7,  // 9: gitidentity.config.v2.Identity.auto_apply_when:type_name -> gitidentity.config.v2.MatchList
+	14, // 10: gitidentity.config.v2.ValueSource.command:type_name -> gitidentity.config.v2.MatchCommand
+	15, // 11: gitidentity.config.v2.ValueSource.shell_script:type_name -> gitidentity.config.v2.MatchShellScript
+	8,  // 12: gitidentity.config.v2.MatchList.match:type_name -> gitidentity.config.v2.Match
+	9,  // 13: gitidentity.config.v2.Match.env:type_name -> gitidentity.config.v2.MatchEnv
+	10, // 14: gitidentity.config.v2.Match.remote:type_name -> gitidentity.config.v2.MatchRemote
+	14, // 15: gitidentity.config.v2.Match.command:type_name -> gitidentity.config.v2.MatchCommand
+	15, // 16: gitidentity.config.v2.Match.shell_script:type_name -> gitidentity.config.v2.MatchShellScript
+	12, // 17: gitidentity.config.v2.Match.location:type_name -> gitidentity.config.v2.MatchLocation
+	13, // 18: gitidentity.config.v2.Match.expression:type_name -> gitidentity.config.v2.MatchExpression
+	11, // 19: gitidentity.config.v2.Match.repository:type_name -> gitidentity.config.v2.MatchRepository
+	18, // 20: gitidentity.config.v2.MatchEnv.to:type_name -> gitidentity.config.v2.Condition
+	18, // 21: gitidentity.config.v2.MatchRemote.name:type_name -> gitidentity.config.v2.Condition
+	18, // 22: gitidentity.config.v2.MatchRemote.url:type_name -> gitidentity.config.v2.Condition
+	18, // 23: gitidentity.config.v2.MatchRepository.remote_name:type_name -> gitidentity.config.v2.Condition
+	18, // 24: gitidentity.config.v2.MatchRepository.scheme:type_name -> gitidentity.config.v2.Condition
+	18, // 25: gitidentity.config.v2.MatchRepository.host:type_name -> gitidentity.config.v2.Condition
+	18, // 26: gitidentity.config.v2.MatchRepository.port:type_name -> gitidentity.config.v2.Condition
+	18, // 27: gitidentity.config.v2.MatchRepository.owner:type_name -> gitidentity.config.v2.Condition
+	18, // 28: gitidentity.config.v2.MatchRepository.name:type_name -> gitidentity.config.v2.Condition
+	18, // 29: gitidentity.config.v2.MatchLocation.path:type_name -> gitidentity.config.v2.Condition
+	18, // 30: gitidentity.config.v2.MatchCommand.output:type_name -> gitidentity.config.v2.Condition
+	18, // 31: gitidentity.config.v2.MatchShellScript.output:type_name -> gitidentity.config.v2.Condition
+	17, // 32: gitidentity.config.v2.Requirements.values:type_name -> gitidentity.config.v2.ValueRequirement
+	18, // 33: gitidentity.config.v2.ValueRequirement.value:type_name -> gitidentity.config.v2.Condition
+	0,  // 34: gitidentity.config.v2.Condition.mode:type_name -> gitidentity.config.v2.ConditionMode
+	3,  // 35: gitidentity.config.v2.Config.ProfilesEntry.value:type_name -> gitidentity.config.v2.Profile
+	7,  // 36: gitidentity.config.v2.Config.RulesEntry.value:type_name -> gitidentity.config.v2.MatchList
+	6,  // 37: gitidentity.config.v2.Identity.ValuesFromEntry.value:type_name -> gitidentity.config.v2.ValueSource
+	5,  // 38: gitidentity.config.v2.Identity.MultiValuesEntry.value:type_name -> gitidentity.config.v2.ValueList
+	39, // [39:39] is the sub-list for method output_type
+	39, // [39:39] is the sub-list for method input_type
+	39, // [39:39] is the sub-list for extension type_name
+	39, // [39:39] is the sub-list for extension extendee
+	0,  // [0:39] is the sub-list for field type_name
 }
 
 func init() { file_gitidentity_config_v2_config_proto_init() }
@@ -795,11 +1565,18 @@ func file_gitidentity_config_v2_config_proto_init() {
 	if File_gitidentity_config_v2_config_proto != nil {
 		return
 	}
-	file_gitidentity_config_v2_config_proto_msgTypes[4].OneofWrappers = []any{
+	file_gitidentity_config_v2_config_proto_msgTypes[5].OneofWrappers = []any{
+		(*ValueSource_Command)(nil),
+		(*ValueSource_ShellScript)(nil),
+	}
+	file_gitidentity_config_v2_config_proto_msgTypes[7].OneofWrappers = []any{
 		(*Match_Env)(nil),
 		(*Match_Remote)(nil),
 		(*Match_Command)(nil),
 		(*Match_ShellScript)(nil),
+		(*Match_Location)(nil),
+		(*Match_Expression)(nil),
+		(*Match_Repository)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -807,7 +1584,7 @@ func file_gitidentity_config_v2_config_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gitidentity_config_v2_config_proto_rawDesc), len(file_gitidentity_config_v2_config_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   11,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -13,10 +16,14 @@ import (
 )
 
 type addOptions struct {
-	id     string
-	name   string
-	email  string
-	values []string
+	id        string
+	name      string
+	email     string
+	values    []string
+	fromRepo  bool
+	keys      []string
+	autoApply bool
+	file      string
 }
 
 func addCmd(r *rootOptions) *cobra.Command {
@@ -24,7 +31,7 @@ func addCmd(r *rootOptions) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add",
 		Short: "Add new identity to configuration",
-		Long:  "Add new identity to gitidentity user configuration file.",
+		Long:  "Add new identity to gitidentity user configuration file. When no flags are provided, identity is created interactively.",
 
 		Run: func(cmd *cobra.Command, args []string) {
 			if !addCmdRun(cmd, r, o, args) {
@@ -37,19 +44,165 @@ func addCmd(r *rootOptions) *cobra.Command {
 	cmd.Flags().StringVar(&o.name, "name", "", "user name value")
 	cmd.Flags().StringVar(&o.email, "email", "", "user email value")
 	cmd.Flags().StringArrayVar(&o.values, "value", nil, "extra git config value")
+	cmd.Flags().BoolVar(&o.fromRepo, "from-repo", false, "capture identity from local config of current repository")
+	cmd.Flags().StringSliceVar(&o.keys, "keys", []string{"user.*"}, "git config keys (or key patterns using * and ?) captured with --from-repo")
+	cmd.Flags().BoolVar(&o.autoApply, "auto-apply", false, "add auto apply rule matching current repository remote URLs (requires --from-repo)")
+	cmd.Flags().StringVar(&o.file, "file", "", "configuration file to write identity to, for example included or conf.d file (main configuration file by default)")
 	return cmd
 }
 
 func addCmdRun(cmd *cobra.Command, r *rootOptions, o *addOptions, args []string) bool {
-	cfg, format, err := identity.ReadConfig(r.config)
-	if errors.Is(err, os.ErrNotExist) {
-		cfg, err = identity.EmptyConfig(), nil
+	lc, err := identity.LoadConfig(r.config, "")
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		showErr(cmd, err)
+		return false
+	}
+
+	var i *configv2.Identity
+	interactive := false
+	prompt := newWizardPrompter(cmd)
+	switch {
+	case o.autoApply && !o.fromRepo:
+		showErr(cmd, errors.New("option \"auto-apply\" requires \"from-repo\""))
+		return false
+	case o.fromRepo:
+		i, err = addCmdRun_fromRepo(cmd.Context(), o)
+		if err != nil {
+			showErr(cmd, err)
+			return false
+		}
+	case addCmd_anyFlagChanged(cmd, "id", "name", "email", "value"):
+		i = addCmd_identityFromFlags(o)
+	default:
+		interactive = true
+		i, err = addCmdRun_interactive(cmd.Context(), prompt)
+		if err != nil {
+			showErr(cmd, err)
+			return false
+		}
+		if i == nil {
+			return true // aborted by user
+		}
+	}
+
+	target := o.file
+	if target == "" && interactive && lc != nil && len(lc.Files) > 1 {
+		if target, err = addCmd_promptFile(prompt, lc, r.config); err != nil {
+			showErr(cmd, err)
+			return false
+		}
 	}
+	if target == "" {
+		target = r.config
+	}
+	cfg, path, format, err := readWritableConfig(target)
 	if err != nil {
 		showErr(cmd, err)
 		return false
 	}
+	if f := addCmd_definedIn(lc, cfg, path, identity.IdentityAsString(i)); f != "" {
+		showErr(cmd, fmt.Errorf("identity %q is already defined in %q", identity.IdentityAsString(i), f))
+		return false
+	}
+	if !addCmd_isLoaded(lc, r.config, path) {
+		showWarn(cmd, fmt.Errorf("configuration file %q is not loaded, include it in the main configuration file or move it to configuration directory", path))
+	}
+
+	cfg.List = append(cfg.GetList(), i)
+	if err := identity.WriteConfig(path, cfg, format); err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	return true
+}
+
+// addCmd_promptFile prompts for one of the user configuration files, the main one or any in configuration directory, as only those are owned by the user
+func addCmd_promptFile(prompt *prompter, lc *identity.LoadedConfig, configPath string) (string, error) {
+	mainPath, _ := identity.ConfigFilePath(configPath)
+	confDir, _ := identity.ConfDirPath(configPath)
+	paths := []string(nil)
+	for _, f := range lc.Files {
+		if f.Layer != identity.LayerUser {
+			continue
+		}
+		if (mainPath != "" && addCmd_samePath(f.Path, mainPath)) || (confDir != "" && addCmd_samePath(filepath.Dir(f.Path), confDir)) {
+			paths = append(paths, f.Path)
+		}
+	}
+	if len(paths) <= 1 {
+		return "", nil // nothing to choose from, main configuration file is used
+	}
+	idx, err := prompt.selectPrompt("Configuration file to add identity to", paths)
+	if err != nil {
+		return "", err
+	}
+	if idx < 0 || idx >= len(paths) {
+		return "", errors.New("no configuration file selected")
+	}
+	return paths[idx], nil
+}
+
+// addCmd_definedIn returns path of the file defining identity, either target file (loaded or not) or any user configuration file
+func addCmd_definedIn(lc *identity.LoadedConfig, target *configv2.Config, targetPath, id string) string {
+	for _, i := range target.GetList() {
+		if identity.IdentityAsString(i) == id {
+			return targetPath
+		}
+	}
+	if lc == nil {
+		return ""
+	}
+	for _, f := range lc.Files {
+		if f.Layer != identity.LayerUser {
+			continue
+		}
+		for _, i := range f.Config.GetList() {
+			if identity.IdentityAsString(i) == id {
+				return f.Path
+			}
+		}
+	}
+	return ""
+}
+
+func addCmd_isLoaded(lc *identity.LoadedConfig, configPath, path string) bool {
+	mainPath, err := identity.ConfigFilePath(configPath)
+	if err != nil {
+		mainPath, _, err = identity.NewConfigFilePath(configPath)
+	}
+	if err == nil && addCmd_samePath(mainPath, path) {
+		return true // main configuration file
+	}
+	if dir, err := identity.ConfDirPath(configPath); err == nil && addCmd_samePath(dir, filepath.Dir(path)) {
+		return true
+	}
+	if lc == nil {
+		return false
+	}
+	for _, f := range lc.Files {
+		if addCmd_samePath(f.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func addCmd_samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
 
+func addCmd_anyFlagChanged(cmd *cobra.Command, names ...string) bool {
+	for _, n := range names {
+		if cmd.Flags().Changed(n) {
+			return true
+		}
+	}
+	return false
+}
+
+func addCmd_identityFromFlags(o *addOptions) *configv2.Identity {
 	i := &configv2.Identity{
 		Identifier: o.id,
 		Values:     make(map[string]string, len(o.values)+2),
@@ -58,14 +211,175 @@ func addCmdRun(cmd *cobra.Command, r *rootOptions, o *addOptions, args []string)
 		k, d, _ := strings.Cut(v, "=")
 		i.Values[k] = d
 	}
-	i.Values[runcmd.GitNameKey] = o.name
-	i.Values[runcmd.GitEmailKey] = o.email
+	if o.name != "" {
+		i.Values[runcmd.GitNameKey] = o.name
+	}
+	if o.email != "" {
+		i.Values[runcmd.GitEmailKey] = o.email
+	}
+	return i
+}
 
-	cfg.List = append(cfg.GetList(), i)
-	identity.SortIdentities(cfg.GetList())
-	if err := identity.WriteConfig(r.config, cfg, format); err != nil {
-		showErr(cmd, err)
-		return false
+func addCmdRun_fromRepo(ctx context.Context, o *addOptions) (*configv2.Identity, error) {
+	i, err := identity.RepositoryIdentityFromKeys(ctx, o.keys)
+	if err != nil {
+		return nil, err
 	}
-	return true
+	for k, v := range addCmd_identityFromFlags(o).GetValues() {
+		i.Values[k] = v
+	}
+	i.Identifier = o.id
+	if o.autoApply {
+		gi, err := runcmd.GitInfoFromDir(ctx)
+		if err != nil {
+			return nil, err
+		}
+		i.AutoApplyWhen = identity.RemoteURLsAsAutoApplyRules(gi)
+	}
+	return i, nil
+}
+
+func addCmdRun_interactive(ctx context.Context, prompt *prompter) (*configv2.Identity, error) {
+	prefill, err := addCmd_promptPrefill(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &configv2.Identity{Values: map[string]string{}}
+	if i.Identifier, err = prompt.textPrompt("Identifier (empty to generate from name and email)", "", nil); err != nil {
+		return nil, err
+	}
+	if i.Values[runcmd.GitNameKey], err = prompt.textPrompt("User name", prefill.GetValues()[runcmd.GitNameKey], addCmd_validateNonEmpty); err != nil {
+		return nil, err
+	}
+	if i.Values[runcmd.GitEmailKey], err = prompt.textPrompt("User email", prefill.GetValues()[runcmd.GitEmailKey], identity.ValidateEmail); err != nil {
+		return nil, err
+	}
+	for {
+		v, err := prompt.textPrompt("Extra git config value as key=value (empty to finish)", "", addCmd_validateKeyValue)
+		if err != nil {
+			return nil, err
+		}
+		if v == "" {
+			break
+		}
+		k, d, _ := strings.Cut(v, "=")
+		i.Values[k] = d
+	}
+	for {
+		more, err := prompt.confirmPrompt("Add auto apply rule")
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
+		}
+		ml, err := addCmd_promptRule(prompt)
+		if err != nil {
+			return nil, err
+		}
+		i.AutoApplyWhen = append(i.AutoApplyWhen, ml)
+	}
+
+	preview, err := identity.MarshalIdentity(i, identity.FormatYAML)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(prompt.out, "\n%s\n", preview)
+	ok, err := prompt.confirmPrompt("Add above identity to configuration")
+	if err != nil || !ok {
+		return nil, err
+	}
+	return i, nil
+}
+
+func addCmd_promptPrefill(ctx context.Context, prompt *prompter) (*configv2.Identity, error) {
+	candidates := []*configv2.Identity{nil}
+	labels := []string{"nothing"}
+	if i, err := identity.RepositoryIdentity(ctx); err == nil && addCmd_hasNameOrEmail(i) {
+		candidates = append(candidates, i)
+		labels = append(labels, fmt.Sprintf("current repository: %s", identity.IdentityAsString(i)))
+	}
+	if i, err := identity.GlobalIdentity(ctx); err == nil && addCmd_hasNameOrEmail(i) {
+		candidates = append(candidates, i)
+		labels = append(labels, fmt.Sprintf("global git identity: %s", identity.IdentityAsString(i)))
+	}
+	if len(candidates) == 1 {
+		return nil, nil //nolint:nilnil // nothing to pre-fill from
+	}
+	idx, err := prompt.selectPrompt("Pre-fill values from", labels)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 || idx >= len(candidates) {
+		return nil, nil //nolint:nilnil // nothing selected
+	}
+	return candidates[idx], nil
+}
+
+var addCmd_ruleModes = []configv2.ConditionMode{
+	configv2.ConditionMode_CONDITION_MODE_UNSPECIFIED,
+	configv2.ConditionMode_CONDITION_MODE_PREFIX,
+	configv2.ConditionMode_CONDITION_MODE_SUFFIX,
+	configv2.ConditionMode_CONDITION_MODE_FULL,
+	configv2.ConditionMode_CONDITION_MODE_SHELL_PATTERN,
+	configv2.ConditionMode_CONDITION_MODE_REGEXP,
+}
+
+func addCmd_promptRule(prompt *prompter) (*configv2.MatchList, error) {
+	subject, err := prompt.selectPrompt("Rule subject", []string{"remote url", "environment variable"})
+	if err != nil {
+		return nil, err
+	}
+	envName := ""
+	if subject == 1 {
+		if envName, err = prompt.textPrompt("Environment variable name", "", addCmd_validateNonEmpty); err != nil {
+			return nil, err
+		}
+	}
+
+	modes := make([]string, len(addCmd_ruleModes))
+	for idx, m := range addCmd_ruleModes {
+		modes[idx] = strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(m.String(), "CONDITION_MODE_"), "_", " "))
+	}
+	modes[0] = "contains"
+	modeIdx, err := prompt.selectPrompt("Condition mode", modes)
+	if err != nil {
+		return nil, err
+	}
+	c := &configv2.Condition{Mode: addCmd_ruleModes[modeIdx]}
+	c.Value, err = prompt.textPrompt("Condition value", "", func(v string) error {
+		return identity.ValidateCondition(&configv2.Condition{Mode: c.GetMode(), Value: v})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &configv2.Match{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{Url: c}}}
+	if subject == 1 {
+		m = &configv2.Match{Subject: &configv2.Match_Env{Env: &configv2.MatchEnv{Name: envName, To: c}}}
+	}
+	return &configv2.MatchList{Match: []*configv2.Match{m}}, nil
+}
+
+func addCmd_hasNameOrEmail(i *configv2.Identity) bool {
+	return i.GetValues()[runcmd.GitNameKey] != "" || i.GetValues()[runcmd.GitEmailKey] != ""
+}
+
+func addCmd_validateNonEmpty(v string) error {
+	if strings.TrimSpace(v) == "" {
+		return errors.New("value cannot be empty")
+	}
+	return nil
+}
+
+func addCmd_validateKeyValue(v string) error {
+	if v == "" {
+		return nil
+	}
+	k, _, found := strings.Cut(v, "=")
+	if !found {
+		return errors.New("expected key=value")
+	}
+	return identity.ValidateGitConfigKey(k)
 }
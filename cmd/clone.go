@@ -10,12 +10,14 @@ import (
 
 	"github.com/spf13/cobra"
 
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
 	"github.com/daishe/gitidentity/internal/gitinfo"
 	"github.com/daishe/gitidentity/internal/identity"
 	"github.com/daishe/gitidentity/internal/runcmd"
 )
 
 type cloneOptions struct {
+	onlyAuto bool
 }
 
 func cloneCmd(r *rootOptions) *cobra.Command {
@@ -23,7 +25,7 @@ func cloneCmd(r *rootOptions) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "clone",
 		Short: "Perform git clone in an identity aware manner",
-		Long:  "Perform git clone in an identity aware manner.",
+		Long:  "Perform git clone in an identity aware manner. All arguments are passed to git clone, except --only-auto option, which makes the command fail instead of prompting, when no identity is matched automatically.",
 
 		DisableFlagParsing: true,
 		Run: func(cmd *cobra.Command, args []string) {
@@ -37,12 +39,17 @@ func cloneCmd(r *rootOptions) *cobra.Command {
 }
 
 func cloneCmdRun(cmd *cobra.Command, r *rootOptions, o *cloneOptions, args []string) bool {
-	cfg, _, err := identity.ReadConfig(r.config)
+	lc, err := loadConfig(cmd, r)
 	if err != nil {
 		showErr(cmd, err)
 		return false
 	}
+	if err := filterCandidates(r, lc); err != nil {
+		showErr(cmd, err)
+		return false
+	}
 
+	args = cloneCmd_extractOptions(o, args)
 	remoteName := cloneCmd_inferRemoteName(cmd.Context(), args)
 	gi := &gitinfo.GitInfo{}
 	for _, a := range args {
@@ -52,15 +59,29 @@ func cloneCmdRun(cmd *cobra.Command, r *rootOptions, o *cloneOptions, args []str
 		gi.Remotes = append(gi.Remotes, &gitinfo.Remote{Name: remoteName, Url: a})
 	}
 
-	i, err := identity.FirstAutoMatchingIdentity(cmd.Context(), cfg.GetList(), gi)
+	var i *configv2.Identity
+	var bindings identity.Bindings
+	autoSelected := false
+	prompt := newPrompter(cmd)
+	ms, err := identity.AllAutoMatchingIdentities(cmd.Context(), lc.Config.GetList(), gi)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	m, err := prompt.selectAutoMatch(cmd.Context(), lc.NearestScopeMatches(ms), o.onlyAuto, lc.Config.GetFirstMatchWins())
 	if err != nil {
 		showErr(cmd, err)
 		return false
 	}
-	if i != nil {
+	switch {
+	case m != nil:
+		i, bindings, autoSelected = m.Identity, m.Bindings, true
 		fmt.Fprintln(cmd.OutOrStdout(), "Automatically selected identity:", identity.IdentityAsString(i))
-	} else {
-		i, err = selectIdentityPrompt(cmd.Context(), cfg.GetList())
+	case o.onlyAuto:
+		showErr(cmd, errors.New("no matching identity"))
+		return false
+	default:
+		i, err = prompt.selectIdentityPrompt(cmd.Context(), lc.Config.GetList(), nil)
 		if err != nil {
 			showErr(cmd, err)
 			return false
@@ -71,21 +92,99 @@ func cloneCmdRun(cmd *cobra.Command, r *rootOptions, o *cloneOptions, args []str
 		}
 	}
 
-	identityAsArgs, err := identity.ApplyIdentityAsArgs(cmd.Context(), i)
+	identityAsArgs, secrets, err := identity.ApplyIdentityAsArgs(cmd.Context(), i, gi, bindings, autoSelected)
 	if err != nil {
 		showErr(cmd, err)
 		return false
 	}
+	dir := cloneCmd_targetDir(args)
+	if len(secrets) > 0 && dir == "" {
+		showErr(cmd, errors.New("unable to determine clone directory to apply values resolved from commands to"))
+		return false
+	}
 
-	args = append(identityAsArgs, args...)
-	args = append([]string{"clone"}, args...)
-	if err := runcmd.CommandPipeOutputAndInput(cmd.Context(), runcmd.GitExecutable(), args...); err != nil {
-		showErr(cmd, runcmd.CommandError(fmt.Sprintf("%s %s", runcmd.GitExecutable(), strings.Join(args, " ")), nil, err))
+	cloneArgs := append([]string{"clone"}, identityAsArgs...)
+	cloneArgs = append(cloneArgs, args...)
+	if err := runcmd.CommandPipeOutputAndInput(cmd.Context(), runcmd.GitExecutable(), cloneArgs...); err != nil {
+		showErr(cmd, runcmd.CommandError(fmt.Sprintf("%s %s", runcmd.GitExecutable(), strings.Join(cloneArgs, " ")), nil, err))
+		return false
+	}
+	if err := identity.ApplyValues(runcmd.WithDir(cmd.Context(), dir), secrets); err != nil { // applied after cloning, so that they are never visible on command line
+		showErr(cmd, err)
 		return false
 	}
 	return true
 }
 
+// cloneCmd_valueOptions lists long git clone options taking value in the next argument, when not given with "="
+var cloneCmd_valueOptions = map[string]bool{
+	"--origin": true, "--branch": true, "--upload-pack": true, "--config": true, "--jobs": true,
+	"--reference": true, "--reference-if-able": true, "--separate-git-dir": true, "--template": true,
+	"--depth": true, "--shallow-since": true, "--shallow-exclude": true, "--server-option": true,
+	"--filter": true, "--bundle-uri": true, "--ref-format": true,
+}
+
+// cloneCmd_targetDir returns directory git clone clones into, either given explicitly or derived from repository the same way git does; empty string is returned when it cannot be determined
+func cloneCmd_targetDir(args []string) string {
+	positional := []string(nil)
+	bare := false
+	for idx := 0; idx < len(args); idx++ {
+		a := args[idx]
+		switch {
+		case a == "--":
+			positional = append(positional, args[idx+1:]...)
+			idx = len(args)
+		case a == "--bare" || a == "--mirror":
+			bare = true
+		case strings.HasPrefix(a, "--"):
+			if cloneCmd_valueOptions[a] {
+				idx++
+			}
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			if at := strings.IndexAny(a[1:], "obucj"); at >= 0 && at == len(a)-2 {
+				idx++ // short option taking value is the last one in pack, so value is in the next argument
+			}
+		default:
+			positional = append(positional, a)
+		}
+	}
+	switch len(positional) {
+	case 1:
+	case 2:
+		return positional[1]
+	default:
+		return ""
+	}
+
+	name := strings.TrimRight(positional[0], "/")
+	name = strings.TrimSuffix(strings.TrimSuffix(name, "/.git"), "/")
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	name = strings.TrimSuffix(strings.TrimSuffix(name, ".git"), ".bundle")
+	if name == "" {
+		return ""
+	}
+	if bare {
+		name += ".git"
+	}
+	return name
+}
+
+// cloneCmd_extractOptions removes gitidentity own options from arguments, leaving the ones for git clone; arguments after "--" separator are never treated as options
+func cloneCmd_extractOptions(o *cloneOptions, args []string) []string {
+	rest := make([]string, 0, len(args))
+	for idx, a := range args {
+		if a == "--" {
+			return append(rest, args[idx:]...)
+		}
+		if a == "--only-auto" {
+			o.onlyAuto = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest
+}
+
 func cloneCmd_inferRemoteName(ctx context.Context, args []string) string {
 	name := "origin"
 
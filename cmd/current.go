@@ -3,12 +3,17 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
 	"github.com/daishe/gitidentity/internal/identity"
+	"github.com/daishe/gitidentity/internal/logging"
 )
 
 type currentOptions struct {
@@ -31,7 +36,7 @@ func currentCmd(r *rootOptions) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVar(&o.all, "all", false, "include all Git configs, not only the local one")
-	cmd.Flags().StringVar(&o.format, "format", "short", "output format, possible values are: short, JSON or YAML")
+	cmd.Flags().StringVar(&o.format, "format", "short", "output format, possible values are: short, long, JSON or YAML")
 	return cmd
 }
 
@@ -44,12 +49,18 @@ func currentCmdRun(cmd *cobra.Command, r *rootOptions, o *currentOptions, args [
 		showErr(cmd, err)
 		return false
 	}
+	if c := currentCmd_configured(r, i); identity.IsExpired(c, time.Now()) {
+		showWarn(cmd, fmt.Errorf("identity %q used in repository expired at %s", identity.IdentityAsString(i), c.GetExpiresAt()))
+	}
 
 	var format identity.Format
 	switch strings.ToLower(o.format) {
 	case "short":
 		fmt.Fprintln(cmd.OutOrStdout(), identity.IdentityAsString(i))
 		return true
+	case "long":
+		currentCmd_long(cmd, r, i)
+		return true
 	case "json":
 		format = identity.FormatJSON
 	case "yaml":
@@ -67,3 +78,64 @@ func currentCmdRun(cmd *cobra.Command, r *rootOptions, o *currentOptions, args [
 	fmt.Fprintln(cmd.OutOrStdout(), string(out))
 	return true
 }
+
+// currentCmd_configured returns identity as currently defined in configuration, so that changes made since it was applied (e.g. extended expiry) are taken into account; identity recorded when it was applied is returned when it is no longer defined
+func currentCmd_configured(r *rootOptions, i *configv2.Identity) *configv2.Identity {
+	lc, err := identity.LoadConfig(r.config, i.GetProfile())
+	if err != nil {
+		logging.Log.Printf("loading configuration: %v", err)
+		return i
+	}
+	for _, c := range lc.Config.GetList() {
+		if identity.IdentityAsString(c) == identity.IdentityAsString(i) {
+			return c
+		}
+	}
+	return i
+}
+
+func currentCmd_long(cmd *cobra.Command, r *rootOptions, i *configv2.Identity) {
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Identity: %s\n", identity.IdentityAsString(i))
+	currentCmd_source(cmd, r, i)
+	if i.GetProfile() != "" {
+		fmt.Fprintf(out, "Profile:  %s\n", i.GetProfile())
+	}
+
+	templates := map[string]string(nil)
+	if last, err := identity.LastAppliedIdentity(cmd.Context()); err == nil && identity.UsesTemplates(last) {
+		templates = last.GetValues()
+	}
+	fmt.Fprintln(out, "Values:")
+	for _, k := range slices.Sorted(maps.Keys(i.GetValues())) {
+		if t, ok := templates[k]; ok && t != i.GetValues()[k] {
+			fmt.Fprintf(out, "  %s = %s (template: %s)\n", k, i.GetValues()[k], t)
+			continue
+		}
+		fmt.Fprintf(out, "  %s = %s\n", k, i.GetValues()[k])
+	}
+	for _, k := range slices.Sorted(maps.Keys(i.GetMultiValues())) {
+		for _, v := range i.GetMultiValues()[k].GetValues() {
+			fmt.Fprintf(out, "  %s += %s\n", k, v)
+		}
+	}
+	for _, k := range i.GetUnsetValues() {
+		fmt.Fprintf(out, "  %s (unset)\n", k)
+	}
+}
+
+func currentCmd_source(cmd *cobra.Command, r *rootOptions, i *configv2.Identity) {
+	out := cmd.OutOrStdout()
+	lc, err := identity.LoadConfig(r.config, "")
+	if err != nil {
+		showWarn(cmd, fmt.Errorf("loading configuration: %w", err))
+		return
+	}
+	f := lc.FileOf(identity.IdentityAsString(i))
+	if f == nil {
+		fmt.Fprintln(out, "Layer:    unknown (identity not found in configuration)")
+		return
+	}
+	fmt.Fprintf(out, "Layer:    %s\n", f.Layer)
+	fmt.Fprintf(out, "File:     %s\n", f.Path)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/gitidentity/internal/identity"
+)
+
+type exportOptions struct {
+	gitconfig string
+}
+
+func exportCmd(r *rootOptions) *cobra.Command {
+	o := &exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export identities into other formats",
+		Long:  "Export identities into other formats, so that they can be used on machines without gitidentity.",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if !exportCmdRun(cmd, r, o, args) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.gitconfig, "gitconfig", "", "directory to write native git config include files and includeIf snippet to")
+	return cmd
+}
+
+func exportCmdRun(cmd *cobra.Command, r *rootOptions, o *exportOptions, args []string) bool {
+	if o.gitconfig == "" {
+		showErr(cmd, errors.New("nothing to export to, use \"gitconfig\" option"))
+		return false
+	}
+
+	lc, err := loadConfig(cmd, r)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+
+	exportable, skipped := identity.ExportableIdentities(lc.Config.GetList(), time.Now())
+	for _, s := range skipped {
+		showWarn(cmd, s)
+	}
+	snippet, exports, err := identity.ExportAsGitConfig(cmd.Context(), exportable, o.gitconfig)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+
+	out := cmd.OutOrStdout()
+	for _, e := range exports {
+		fmt.Fprintf(out, "Exported %s to %s\n", identity.IdentityAsString(e.Identity), e.File)
+		for _, c := range e.Conditions {
+			fmt.Fprintf(out, "  included when %s\n", c)
+		}
+		for _, s := range e.Skipped {
+			showWarn(cmd, fmt.Sprintf("identity %s: skipped %s", identity.IdentityAsString(e.Identity), s))
+		}
+		if len(e.Conditions) == 0 {
+			fmt.Fprintln(out, "  not included automatically, include it manually if needed")
+		}
+	}
+	fmt.Fprintf(out, "\nTo use exported identities, add the following to global git config:\n\n[include]\n\tpath = %s\n", filepath.ToSlash(snippet))
+	return true
+}
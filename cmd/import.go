@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/gitidentity/internal/identity"
+)
+
+type importOptions struct {
+	scan      string
+	gitconfig bool
+	yes       bool
+}
+
+func importCmd(r *rootOptions) *cobra.Command {
+	o := &importOptions{}
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import identities from existing setup",
+		Long:  "Import identities from existing setup into gitidentity user configuration file. Each proposed identity is confirmed before it is added.",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if !importCmdRun(cmd, r, o, args) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.scan, "scan", "", "directory to scan for repositories with locally set identities")
+	cmd.Flags().BoolVar(&o.gitconfig, "gitconfig", false, "import identities from includeIf sections of global git config")
+	cmd.Flags().BoolVarP(&o.yes, "yes", "y", false, "import all proposed identities without confirmation")
+	return cmd
+}
+
+func importCmdRun(cmd *cobra.Command, r *rootOptions, o *importOptions, args []string) bool {
+	if o.scan == "" && !o.gitconfig {
+		showErr(cmd, errors.New("nothing to import from, use \"scan\" or \"gitconfig\" option"))
+		return false
+	}
+
+	cfg, path, format, err := readWritableConfig(r.config)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+
+	proposals := []*identity.Proposal(nil)
+	if o.scan != "" {
+		scanned, err := identity.ScanRepositories(cmd.Context(), o.scan)
+		if err != nil {
+			showErr(cmd, err)
+			return false
+		}
+		proposals = append(proposals, scanned...)
+	}
+	if o.gitconfig {
+		included, skipped, err := identity.IncludeIfProposals(cmd.Context())
+		if err != nil {
+			showErr(cmd, err)
+			return false
+		}
+		for _, s := range skipped {
+			showWarn(cmd, fmt.Sprintf("skipped %s", s))
+		}
+		proposals = append(proposals, included...)
+	}
+
+	imported := 0
+	prompt := newPrompter(cmd)
+	for _, p := range proposals {
+		name := identity.IdentityAsString(p.Identity)
+		if existing := identity.IdentityWithNameAndEmail(cfg.GetList(), p.Identity); existing != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "Skipping %s: already configured as %s\n", name, identity.IdentityAsString(existing))
+			continue
+		}
+		ok, err := importCmd_confirm(cmd, prompt, o, p)
+		if err != nil {
+			showErr(cmd, err)
+			return false
+		}
+		if ok {
+			cfg.List = append(cfg.GetList(), p.Identity)
+			imported++
+		}
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Imported %d identities\n", imported)
+	if imported == 0 {
+		return true
+	}
+	if err := identity.WriteConfig(path, cfg, format); err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	return true
+}
+
+func importCmd_confirm(cmd *cobra.Command, prompt *prompter, o *importOptions, p *identity.Proposal) (bool, error) {
+	preview, err := identity.MarshalIdentity(p.Identity, identity.FormatYAML)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "\nProposed identity %s:\n%s", identity.IdentityAsString(p.Identity), preview)
+	if len(p.Origins) != 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "# found in: %s\n", strings.Join(p.Origins, ", "))
+	}
+	if o.yes {
+		return true, nil
+	}
+	return prompt.confirmPrompt(fmt.Sprintf("Import %s", identity.IdentityAsString(p.Identity)))
+}
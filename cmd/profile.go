@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/gitidentity/internal/identity"
+)
+
+func profileCmd(r *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage configuration profiles",
+		Long:  "Manage configuration profiles, named sets of identities defined in configuration, of which one can be active at a time.",
+	}
+
+	cmd.AddCommand(profileListCmd(r))
+	cmd.AddCommand(profileCurrentCmd(r))
+	cmd.AddCommand(profileUseCmd(r))
+	return cmd
+}
+
+type profileListOptions struct {
+}
+
+func profileListCmd(r *rootOptions) *cobra.Command {
+	o := &profileListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List configuration profiles",
+		Long:  "List profiles defined in configuration, marking the active one.",
+		Args:  cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if !profileListCmdRun(cmd, r, o, args) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	return cmd
+}
+
+func profileListCmdRun(cmd *cobra.Command, r *rootOptions, o *profileListOptions, args []string) bool {
+	lc, err := identity.LoadConfig(r.config, "")
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	active, err := activeProfile(r)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	for _, name := range lc.Profiles() {
+		if name == active {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s (active)\n", name)
+			continue
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), name)
+	}
+	return true
+}
+
+type profileCurrentOptions struct {
+}
+
+func profileCurrentCmd(r *rootOptions) *cobra.Command {
+	o := &profileCurrentOptions{}
+	cmd := &cobra.Command{
+		Use:   "current",
+		Short: "Show active configuration profile",
+		Long:  "Show active configuration profile, selected with --profile option, GITIDENTITY_PROFILE environment variable or persisted as the default one.",
+		Args:  cobra.NoArgs,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if !profileCurrentCmdRun(cmd, r, o, args) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	return cmd
+}
+
+func profileCurrentCmdRun(cmd *cobra.Command, r *rootOptions, o *profileCurrentOptions, args []string) bool {
+	active, err := activeProfile(r)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	if active != "" {
+		fmt.Fprintln(cmd.OutOrStdout(), active)
+	}
+	return true
+}
+
+type profileUseOptions struct {
+	none bool
+}
+
+func profileUseCmd(r *rootOptions) *cobra.Command {
+	o := &profileUseOptions{}
+	cmd := &cobra.Command{
+		Use:   "use [profile]",
+		Short: "Set default configuration profile",
+		Long:  "Persist the default configuration profile, used when no profile is selected with --profile option or GITIDENTITY_PROFILE environment variable.",
+		Args:  cobra.MaximumNArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if !profileUseCmdRun(cmd, r, o, args) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().BoolVar(&o.none, "none", false, "remove the default profile, so that no profile is active")
+	return cmd
+}
+
+func profileUseCmdRun(cmd *cobra.Command, r *rootOptions, o *profileUseOptions, args []string) bool {
+	if o.none == (len(args) == 1) {
+		showErr(cmd, errors.New("either profile name or --none option is required"))
+		return false
+	}
+
+	profile := ""
+	if !o.none {
+		profile = args[0]
+		lc, err := identity.LoadConfig(r.config, "")
+		if err != nil {
+			showErr(cmd, err)
+			return false
+		}
+		if !slices.Contains(lc.Profiles(), profile) {
+			showErr(cmd, fmt.Errorf("profile %q is not defined", profile))
+			return false
+		}
+	}
+	if err := identity.SetDefaultProfile(r.config, profile); err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	if r.profile != "" && r.profile != profile {
+		showWarn(cmd, fmt.Sprintf("profile %q selected with --profile option or GITIDENTITY_PROFILE environment variable takes precedence over the default one", r.profile))
+	}
+	return true
+}
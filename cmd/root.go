@@ -2,17 +2,25 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
-	"github.com/daishe/gitidentity/internal/logging"
 	"github.com/spf13/cobra"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/identity"
+	"github.com/daishe/gitidentity/internal/logging"
 )
 
 type rootOptions struct {
 	config    string
 	changeDir string
 	logging   bool
+	tags      []string
+	profile   string
 }
 
 func rootCmd() *cobra.Command {
@@ -37,8 +45,10 @@ func rootCmd() *cobra.Command {
 		},
 	}
 
-	cmd.PersistentFlags().StringVar(&o.config, "config", "", "path to user configuration file")
+	cmd.PersistentFlags().StringVar(&o.config, "config", os.Getenv("GITIDENTITY_CONFIG"), "path to user configuration file, used instead of the default one together with conf.d directory next to it, disables system configuration, while directory configuration files are still loaded (defaults to value of GITIDENTITY_CONFIG environment variable)")
 	cmd.PersistentFlags().BoolVar(&o.logging, "debug", false, "dump debug logs to stderr")
+	cmd.PersistentFlags().StringVar(&o.profile, "profile", os.Getenv("GITIDENTITY_PROFILE"), "configuration profile to use, instead of the default one (defaults to value of GITIDENTITY_PROFILE environment variable)")
+	cmd.PersistentFlags().StringArrayVar(&o.tags, "tag", nil, "only consider identities with the tag, can be repeated to require multiple tags")
 	cmd.PersistentFlags().StringVarP(&o.changeDir, "change-directory", "C", "", "run as if gitidentiry was started in the provided path, instead of the current working directory")
 
 	cmd.AddCommand(addCmd(o))
@@ -46,16 +56,75 @@ func rootCmd() *cobra.Command {
 	cmd.AddCommand(setCmd(o))
 	cmd.AddCommand(unsetCmd(o))
 	cmd.AddCommand(cloneCmd(o))
+	cmd.AddCommand(importCmd(o))
+	cmd.AddCommand(exportCmd(o))
+	cmd.AddCommand(whoamiCmd(o))
+	cmd.AddCommand(profileCmd(o))
 	cmd.AddCommand(versionCmd(o))
 	return cmd
 }
 
+func readWritableConfig(path string) (*configv2.Config, string, identity.Format, error) {
+	cfg, format, err := identity.ReadConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		path, format, err = identity.NewConfigFilePath(path)
+		return identity.EmptyConfig(), path, format, err
+	}
+	if err != nil {
+		return nil, "", format, err
+	}
+	path, err = identity.ConfigFilePath(path)
+	return cfg, path, format, err
+}
+
+// activeProfile returns profile selected with --profile option or GITIDENTITY_PROFILE environment variable, or the persisted default profile
+func activeProfile(r *rootOptions) (string, error) {
+	if r.profile != "" {
+		return r.profile, nil
+	}
+	return identity.DefaultProfile(r.config)
+}
+
+func loadConfig(cmd *cobra.Command, r *rootOptions) (*identity.LoadedConfig, error) {
+	profile, err := activeProfile(r)
+	if err != nil {
+		return nil, err
+	}
+	lc, err := identity.LoadConfig(r.config, profile)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range lc.Warnings {
+		showWarn(cmd, w)
+	}
+	return lc, nil
+}
+
+// filterCandidates restricts identities of loaded configuration to active identities having tags requested with --tag option
+func filterCandidates(r *rootOptions, lc *identity.LoadedConfig) error {
+	lc.Config.List = identity.ActiveIdentities(lc.Config.GetList(), time.Now())
+	if len(r.tags) == 0 {
+		return nil
+	}
+	lc.Config.List = identity.FilterByTags(lc.Config.GetList(), r.tags)
+	if len(lc.Config.GetList()) == 0 {
+		return fmt.Errorf("no active identities tagged %s", strings.Join(r.tags, ", "))
+	}
+	return nil
+}
+
 func showErr(cmd *cobra.Command, msg interface{}) {
 	if msg != nil {
 		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", msg)
 	}
 }
 
+func showWarn(cmd *cobra.Command, msg interface{}) {
+	if msg != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", msg)
+	}
+}
+
 func Execute(ctx context.Context) error {
 	return rootCmd().ExecuteContext(ctx)
 }
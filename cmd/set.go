@@ -1,14 +1,15 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
 	"github.com/daishe/gitidentity/internal/identity"
 	"github.com/daishe/gitidentity/internal/runcmd"
 )
@@ -43,14 +44,28 @@ func setCmdRun(cmd *cobra.Command, r *rootOptions, o *setOptions, args []string)
 		return false
 	}
 
-	cfg, _, err := identity.ReadConfig(r.config)
+	lc, err := loadConfig(cmd, r)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	if err := filterCandidates(r, lc); err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	gi, err := runcmd.GitInfoFromDir(cmd.Context())
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	req, err := identity.ReadRequirements(gi.Dir)
 	if err != nil {
 		showErr(cmd, err)
 		return false
 	}
 
 	if !o.noAuto {
-		i, err := setCmdRun_auto(cmd.Context(), cfg.GetList())
+		i, err := setCmdRun_auto(cmd, o, lc, gi, req)
 		if err != nil {
 			showErr(cmd, err)
 			return false
@@ -65,7 +80,12 @@ func setCmdRun(cmd *cobra.Command, r *rootOptions, o *setOptions, args []string)
 		return false
 	}
 
-	_, err = setCmdRun_manual(cmd.Context(), cfg.GetList())
+	list, err := setCmd_compliant(cmd, lc.Config.GetList(), gi, req)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	_, err = setCmdRun_manual(cmd, list, gi, req)
 	if err != nil {
 		showErr(cmd, err)
 		return false
@@ -73,36 +93,76 @@ func setCmdRun(cmd *cobra.Command, r *rootOptions, o *setOptions, args []string)
 	return true
 }
 
-func setCmdRun_auto(ctx context.Context, list []*configv2.Identity) (*configv2.Identity, error) {
-	gi, err := runcmd.GitInfoFromDir(ctx)
+func setCmdRun_auto(cmd *cobra.Command, o *setOptions, lc *identity.LoadedConfig, gi *gitinfo.GitInfo, req *configv2.Requirements) (*configv2.Identity, error) {
+	ctx := cmd.Context()
+	ms, err := identity.AllAutoMatchingIdentities(ctx, lc.Config.GetList(), gi)
 	if err != nil {
 		return nil, err
 	}
 
-	i, err := identity.FirstAutoMatchingIdentity(ctx, list, gi)
-	if err != nil {
+	compliant := make([]*identity.AutoMatch, 0, len(ms))
+	for _, m := range lc.NearestScopeMatches(ms) {
+		violations, unchecked, err := identity.RequirementViolations(m.Identity, gi, m.Bindings, true, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(unchecked) > 0 {
+			showWarn(cmd, fmt.Errorf("automatically matched identity %q cannot be checked against repository requirements: %s", identity.IdentityAsString(m.Identity), strings.Join(unchecked, "; ")))
+		}
+		if len(violations) > 0 {
+			showWarn(cmd, fmt.Errorf("automatically matched identity %q does not satisfy repository requirements: %s", identity.IdentityAsString(m.Identity), strings.Join(violations, "; ")))
+			continue
+		}
+		compliant = append(compliant, m)
+	}
+
+	m, err := newPrompter(cmd).selectAutoMatch(ctx, compliant, o.onlyAuto, lc.Config.GetFirstMatchWins())
+	if err != nil || m == nil {
 		return nil, err
 	}
-	if i == nil {
-		return nil, nil //nolint:nilnil // no identity selected
+	if err := identity.ApplyIdentity(ctx, m.Identity, gi, m.Bindings, true); err != nil {
+		return nil, err
 	}
+	return m.Identity, nil
+}
 
-	if err := identity.ApplyIdentity(ctx, i); err != nil {
+func setCmd_compliant(cmd *cobra.Command, list []*configv2.Identity, gi *gitinfo.GitInfo, req *configv2.Requirements) ([]*configv2.Identity, error) {
+	compliant, violations, err := identity.CompliantIdentities(list, gi, req)
+	if err != nil {
 		return nil, err
 	}
-	return i, nil
+	if len(violations) == 0 {
+		return compliant, nil
+	}
+	if len(compliant) == 0 {
+		msg := "no identity satisfies repository requirements:"
+		for _, i := range list {
+			msg += fmt.Sprintf("\n  %s: %s", identity.IdentityAsString(i), strings.Join(violations[identity.IdentityAsString(i)], "; "))
+		}
+		return nil, errors.New(msg)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Hiding %d identities not satisfying repository requirements (see %s)\n", len(violations), identity.RequirementsFileName)
+	return compliant, nil
 }
 
-func setCmdRun_manual(ctx context.Context, list []*configv2.Identity) (*configv2.Identity, error) {
-	i, err := selectIdentityPrompt(ctx, list)
+func setCmdRun_manual(cmd *cobra.Command, list []*configv2.Identity, gi *gitinfo.GitInfo, req *configv2.Requirements) (*configv2.Identity, error) {
+	ctx := cmd.Context()
+	i, err := newPrompter(cmd).selectIdentityPrompt(ctx, list, nil)
 	if err != nil {
 		return nil, err
 	}
 	if i == nil {
 		return nil, errors.New("no identity selected")
 	}
+	_, unchecked, err := identity.RequirementViolations(i, gi, nil, false, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(unchecked) > 0 {
+		showWarn(cmd, fmt.Errorf("selected identity %q cannot be checked against repository requirements: %s", identity.IdentityAsString(i), strings.Join(unchecked, "; ")))
+	}
 
-	if err := identity.ApplyIdentity(ctx, i); err != nil {
+	if err := identity.ApplyIdentity(ctx, i, gi, nil, false); err != nil {
 		return nil, err
 	}
 	return i, err
@@ -5,8 +5,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -14,16 +16,49 @@ import (
 	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
 	"github.com/daishe/gitidentity/internal/identity"
 	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
 )
 
-func selectIdentityPrompt(ctx context.Context, list []*configv2.Identity) (*configv2.Identity, error) {
+type prompter struct {
+	in        *bufio.Reader // shared by all prompts, so that buffered input is not lost between them
+	out       io.Writer
+	lineBased bool // read plain lines instead of being interactive
+}
+
+// newPrompter returns prompter of command, reading plain lines on windows
+func newPrompter(cmd *cobra.Command) *prompter {
+	return &prompter{
+		in:        bufio.NewReader(cmd.InOrStdin()),
+		out:       cmd.OutOrStdout(),
+		lineBased: runtime.GOOS == "windows",
+	}
+}
+
+// newWizardPrompter returns prompter of command for series of questions, reading plain lines also when standard input is not a terminal, where interactive prompts would consume input meant for later prompts
+func newWizardPrompter(cmd *cobra.Command) *prompter {
+	p := newPrompter(cmd)
+	p.lineBased = p.lineBased || !readline.IsTerminal(int(os.Stdin.Fd()))
+	return p
+}
+
+func (p *prompter) selectIdentityPrompt(ctx context.Context, list []*configv2.Identity, matched map[string]bool) (*configv2.Identity, error) {
 	if len(list) == 0 {
 		return nil, errors.New("no identities configured")
 	}
+	list = slices.Clone(list)
+	identity.SortIdentities(list) // display only, configuration order matters for auto matching
 
 	stringifiedIdentities := identity.IdentitiesAsStrings(list)
-	addMetadataToStringifiedIdentity(ctx, stringifiedIdentities)
-	idx, err := selectPrompt("Select identity", stringifiedIdentities)
+	addMetadataToStringifiedIdentity(ctx, stringifiedIdentities, matched)
+	items := make([]promptItem, len(list))
+	for idx, i := range list {
+		items[idx] = promptItem{
+			Label:   stringifiedIdentities[idx],
+			Details: identityDetails(i),
+			search:  stringifiedIdentities[idx] + " " + strings.Join(i.GetTags(), " "),
+		}
+	}
+	idx, err := p.selectItemsPrompt("Select identity", items)
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +68,7 @@ func selectIdentityPrompt(ctx context.Context, list []*configv2.Identity) (*conf
 	return list[idx], nil
 }
 
-func addMetadataToStringifiedIdentity(ctx context.Context, stringifiedIdentities []string) {
+func addMetadataToStringifiedIdentity(ctx context.Context, stringifiedIdentities []string, matched map[string]bool) {
 	current, err := identity.CurrentIdentity(ctx, false)
 	currentStr := identity.IdentityAsString(current)
 	if err != nil {
@@ -44,31 +79,103 @@ func addMetadataToStringifiedIdentity(ctx context.Context, stringifiedIdentities
 	if err != nil {
 		globalStr = "" // setting global to empty will effectively result in skipping 'global' metadata tag
 	}
-	for idx := range stringifiedIdentities {
-		switch {
-		case stringifiedIdentities[idx] == currentStr && currentStr == globalStr:
-			stringifiedIdentities[idx] += " (current, global)"
-		case stringifiedIdentities[idx] == currentStr:
-			stringifiedIdentities[idx] += " (current)"
-		case stringifiedIdentities[idx] == globalStr:
-			stringifiedIdentities[idx] += " (global)"
+	for idx, s := range stringifiedIdentities {
+		tags := []string(nil)
+		if matched[s] {
+			tags = append(tags, "matched")
+		}
+		if s == currentStr {
+			tags = append(tags, "current")
+		}
+		if s == globalStr {
+			tags = append(tags, "global")
+		}
+		if len(tags) > 0 {
+			stringifiedIdentities[idx] += " (" + strings.Join(tags, ", ") + ")"
 		}
 	}
 }
 
-func selectPrompt(msg string, list []string) (int, error) {
-	if runtime.GOOS == "windows" {
-		return selectPrompt_windows(msg, list)
+// selectAutoMatch picks one of automatically matched identities, asking when several identities with the same priority match
+func (p *prompter) selectAutoMatch(ctx context.Context, ms []*identity.AutoMatch, onlyAuto, firstMatchWins bool) (*identity.AutoMatch, error) {
+	if len(ms) == 0 {
+		return nil, nil //nolint:nilnil // nothing matched
+	}
+	candidates := identity.TopPriorityMatches(ms)
+	if len(candidates) == 1 || firstMatchWins {
+		return candidates[0], nil
+	}
+
+	list := make([]*configv2.Identity, len(candidates))
+	matched := make(map[string]bool, len(candidates))
+	for idx, m := range candidates {
+		list[idx] = m.Identity
+		matched[identity.IdentityAsString(m.Identity)] = true
+	}
+	if onlyAuto {
+		return nil, fmt.Errorf("ambiguous automatic match, candidates: %s", strings.Join(identity.IdentitiesAsStrings(list), ", "))
+	}
+	i, err := p.selectIdentityPrompt(ctx, list, matched)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range candidates {
+		if m.Identity == i {
+			return m, nil
+		}
+	}
+	return nil, errors.New("no identity selected")
+}
+
+func (p *prompter) selectPrompt(msg string, list []string) (int, error) {
+	items := make([]promptItem, len(list))
+	for idx, li := range list {
+		items[idx] = promptItem{Label: li}
+	}
+	return p.selectItemsPrompt(msg, items)
+}
+
+type promptItem struct {
+	Label   string
+	Details string
+	search  string // text matched when searching, defaults to label
+}
+
+// identityDetails returns description and tags of identity shown in details pane of prompt
+func identityDetails(i *configv2.Identity) string {
+	details := []string(nil)
+	if i.GetDescription() != "" {
+		details = append(details, i.GetDescription())
+	}
+	if len(i.GetTags()) > 0 {
+		details = append(details, "Tags: "+strings.Join(i.GetTags(), ", "))
+	}
+	return strings.Join(details, "\n")
+}
+
+func (p *prompter) selectItemsPrompt(msg string, items []promptItem) (int, error) {
+	if p.lineBased {
+		return p.selectPrompt_line(msg, items)
 	}
 
 	component := promptui.Select{
 		Label:             msg,
-		Items:             list,
+		Items:             items,
 		Size:              20,
 		HideHelp:          true,
 		StartInSearchMode: true,
+		Templates: &promptui.SelectTemplates{
+			Active:   fmt.Sprintf("%s {{ .Label | underline }}", promptui.IconSelect),
+			Inactive: "  {{ .Label }}",
+			Selected: fmt.Sprintf(`{{ "%s" | green }} {{ .Label | faint }}`, promptui.IconGood),
+			Details:  "{{ with .Details }}\n{{ . | faint }}{{ end }}",
+		},
 		Searcher: func(input string, i int) bool {
-			return strings.Contains(list[i], input)
+			search := items[i].search
+			if search == "" {
+				search = items[i].Label
+			}
+			return strings.Contains(search, input)
 		},
 		Keys: &promptui.SelectKeys{
 			Prev:     promptui.Key{Code: readline.CharPrev, Display: "↑"},
@@ -86,20 +193,125 @@ func selectPrompt(msg string, list []string) (int, error) {
 	return idx, nil
 }
 
-func selectPrompt_windows(msg string, list []string) (int, error) {
-	for i, li := range list {
-		fmt.Printf("  %d: %s\n", i+1, li)
+// selectPrompt_line selects item by its number, its label or text found in exactly one item, as in search mode of interactive prompt; asks again when selection is invalid or ambiguous
+func (p *prompter) selectPrompt_line(msg string, items []promptItem) (int, error) {
+	for i, item := range items {
+		fmt.Fprintf(p.out, "  %d: %s\n", i+1, item.Label)
 	}
-	fmt.Printf("%s: ", msg)
-	reader := bufio.NewReader(os.Stdin)
-	line, err := reader.ReadString('\n')
-	fmt.Print("\n")
+	for {
+		fmt.Fprintf(p.out, "%s: ", msg)
+		line, err := p.in.ReadString('\n')
+		fmt.Fprint(p.out, "\n")
+		if err != nil {
+			return -1, err
+		}
+		idx, err := selectPrompt_lineItem(strings.TrimSpace(line), items)
+		if err != nil {
+			fmt.Fprintf(p.out, "%v\n", err)
+			continue
+		}
+		return idx, nil
+	}
+}
+
+func selectPrompt_lineItem(input string, items []promptItem) (int, error) {
+	if idx, err := strconv.Atoi(input); err == nil && idx >= 1 && idx <= len(items) {
+		return idx - 1, nil
+	}
+	if idx := slices.IndexFunc(items, func(item promptItem) bool { return item.Label == input }); idx >= 0 {
+		return idx, nil
+	}
+	found := []int(nil)
+	for idx, item := range items {
+		search := item.search
+		if search == "" {
+			search = item.Label
+		}
+		if input != "" && strings.Contains(search, input) {
+			found = append(found, idx)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return -1, fmt.Errorf("invalid selection %q", input)
+	case 1:
+		return found[0], nil
+	}
+	labels := make([]string, len(found))
+	for i, idx := range found {
+		labels[i] = items[idx].Label
+	}
+	return -1, fmt.Errorf("ambiguous selection %q, matching: %s", input, strings.Join(labels, ", "))
+}
+
+func (p *prompter) textPrompt(msg, defaultValue string, validate func(string) error) (string, error) {
+	if p.lineBased {
+		return p.textPrompt_line(msg, defaultValue, validate)
+	}
+
+	component := promptui.Prompt{
+		Label:     msg,
+		Default:   defaultValue,
+		AllowEdit: true,
+		Validate:  validate,
+	}
+	return component.Run()
+}
+
+func (p *prompter) textPrompt_line(msg, defaultValue string, validate func(string) error) (string, error) {
+	for {
+		if defaultValue != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", msg, defaultValue)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", msg)
+		}
+		line, err := p.in.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimRight(line, "\r\n")
+		if value == "" {
+			value = defaultValue
+		}
+		if validate == nil {
+			return value, nil
+		}
+		if err := validate(value); err != nil {
+			fmt.Fprintf(p.out, "%v\n", err)
+			continue
+		}
+		return value, nil
+	}
+}
+
+func (p *prompter) confirmPrompt(msg string) (bool, error) {
+	if p.lineBased {
+		return p.confirmPrompt_line(msg)
+	}
+
+	component := promptui.Prompt{
+		Label:     msg,
+		IsConfirm: true,
+	}
+	_, err := component.Run()
+	if errors.Is(err, promptui.ErrAbort) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *prompter) confirmPrompt_line(msg string) (bool, error) {
+	fmt.Fprintf(p.out, "%s [y/N]: ", msg)
+	line, err := p.in.ReadString('\n')
 	if err != nil {
-		return -1, err
+		return false, err
 	}
-	idx, err := strconv.Atoi(strings.TrimSpace(line))
-	if err != nil || idx < 1 || idx > len(list) {
-		return -1, errors.New("invalid selection")
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
 	}
-	return idx - 1, nil
+	return false, nil
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"github.com/daishe/gitidentity/internal/cache"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+	"github.com/daishe/gitidentity/internal/identity"
+	"github.com/daishe/gitidentity/internal/logging"
+	"github.com/daishe/gitidentity/internal/runcmd"
+)
+
+type whoamiOptions struct {
+	format string
+	check  bool
+}
+
+type whoamiIdentity struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+}
+
+type whoamiCacheEntry struct {
+	Managed  bool           `json:"managed"`
+	Identity whoamiIdentity `json:"identity"`
+}
+
+func whoamiCmd(r *rootOptions) *cobra.Command {
+	o := &whoamiOptions{}
+	cmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show current identity for shell prompts",
+		Long:  "Show identity set in current repository, optimized for shell prompts. Results are cached until the repository or user configuration changes. Nothing is printed outside of a repository.",
+
+		Run: func(cmd *cobra.Command, args []string) {
+			if !whoamiCmdRun(cmd, r, o, args) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.format, "format", "{{.Identifier}}", "Go template used for output, available fields are: .Identifier, .Name and .Email")
+	cmd.Flags().BoolVar(&o.check, "check", false, "print nothing and exit with non-zero exit code when repository has no gitidentity managed identity")
+	return cmd
+}
+
+func whoamiCmdRun(cmd *cobra.Command, r *rootOptions, o *whoamiOptions, args []string) bool {
+	tmpl, err := template.New("whoami").Parse(o.format)
+	if err != nil {
+		showErr(cmd, fmt.Errorf("parsing format: %w", err))
+		return false
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	gitConfig, err := gitinfo.LocalConfigPath(wd)
+	if errors.Is(err, gitinfo.ErrNotRepository) {
+		return !o.check
+	}
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+
+	e, err := whoamiCmd_current(cmd.Context(), r.config, gitConfig)
+	if err != nil {
+		showErr(cmd, err)
+		return false
+	}
+	if o.check {
+		return e.Managed
+	}
+	if !e.Managed {
+		return true
+	}
+
+	if err := tmpl.Execute(cmd.OutOrStdout(), e.Identity); err != nil {
+		showErr(cmd, fmt.Errorf("executing format: %w", err))
+		return false
+	}
+	fmt.Fprintln(cmd.OutOrStdout())
+	return true
+}
+
+func whoamiCmd_current(ctx context.Context, configPath, gitConfig string) (*whoamiCacheEntry, error) {
+	key := whoamiCmd_cacheKey(configPath, gitConfig)
+	e := &whoamiCacheEntry{}
+	if cache.Load("whoami:"+gitConfig, key, e) {
+		return e, nil
+	}
+
+	i, err := identity.CurrentIdentity(ctx, false)
+	switch {
+	case errors.Is(err, identity.ErrNoCurrentIdentity):
+		e.Managed = false
+	case err != nil:
+		return nil, err
+	default:
+		e.Managed = true
+		e.Identity = whoamiIdentity{
+			Identifier: identity.IdentityAsString(i),
+			Name:       i.GetValues()[runcmd.GitNameKey],
+			Email:      i.GetValues()[runcmd.GitEmailKey],
+		}
+	}
+
+	if err := cache.Store("whoami:"+gitConfig, key, e); err != nil {
+		logging.Log.Printf("storing whoami cache failed: %v", err)
+	}
+	return e, nil
+}
+
+// whoamiCmd_cacheKey returns key of cached entry; whoami reports identity recorded in repository config, which gitidentity configuration does not affect, so stamp of repository config alone keeps the entry valid; main configuration file is stamped only to drop entries once user edits configuration, other files are not, as finding them requires loading configuration, which cache is meant to avoid
+func whoamiCmd_cacheKey(configPath, gitConfig string) string {
+	key := whoamiCmd_fileStamp(gitConfig)
+	if p, err := identity.ConfigFilePath(configPath); err == nil {
+		key += "|" + whoamiCmd_fileStamp(p)
+	}
+	return key
+}
+
+func whoamiCmd_fileStamp(path string) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return path + ":missing"
+	}
+	return fmt.Sprintf("%s:%d:%d", path, fi.ModTime().UnixNano(), fi.Size())
+}
@@ -11,7 +11,7 @@ import (
 var (
 	Version              = "development"
 	Commit               = "?"
-	ConfigurationVersion = "v2"
+	ConfigurationVersion = "v3"
 )
 
 func main() {
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/daishe/gitidentity/internal/logging"
+)
+
+type entry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+func entryPath(name string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(dir, "gitidentity", hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func Load(name, key string, v any) bool {
+	p, err := entryPath(name)
+	if err != nil {
+		logging.Log.Printf("cache %q unavailable: %v", name, err)
+		return false
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		logging.Log.Printf("cache %q miss: %v", name, err)
+		return false
+	}
+	e := entry{}
+	if err := json.Unmarshal(data, &e); err != nil || e.Key != key {
+		logging.Log.Printf("cache %q miss: stale or invalid entry", name)
+		return false
+	}
+	if err := json.Unmarshal(e.Value, v); err != nil {
+		logging.Log.Printf("cache %q miss: %v", name, err)
+		return false
+	}
+	logging.Log.Printf("cache %q hit", name)
+	return true
+}
+
+func Store(name, key string, v any) error {
+	p, err := entryPath(name)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("making cache directory: %w", err)
+	}
+	tmpName := fmt.Sprintf("%s.%d.tmp", p, os.Getpid())
+	if err := os.WriteFile(tmpName, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, p)
+}
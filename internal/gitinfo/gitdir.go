@@ -0,0 +1,93 @@
+package gitinfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotRepository = errors.New("not a git repository")
+
+func LocalConfigPath(dir string) (string, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	commonDir, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if errors.Is(err, os.ErrNotExist) {
+		return filepath.Join(gitDir, "config"), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	p := strings.TrimSpace(string(commonDir))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(gitDir, p)
+	}
+	return filepath.Join(p, "config"), nil
+}
+
+// WorktreeDir returns top-level directory of the worktree containing dir
+func WorktreeDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNotRepository
+		}
+		dir = parent
+	}
+}
+
+func findGitDir(dir string) (string, error) {
+	if p := os.Getenv("GIT_DIR"); p != "" {
+		return filepath.Abs(p)
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		p := filepath.Join(dir, ".git")
+		fi, err := os.Stat(p)
+		switch {
+		case err == nil && fi.IsDir():
+			return p, nil
+		case err == nil:
+			return readGitDirFile(p)
+		case !errors.Is(err, os.ErrNotExist):
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrNotRepository
+		}
+		dir = parent
+	}
+}
+
+func readGitDirFile(p string) (string, error) {
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	gitDir, found := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir:")
+	if !found {
+		return "", fmt.Errorf("invalid gitdir file %q", p)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(p), gitDir)
+	}
+	return gitDir, nil
+}
@@ -1,7 +1,24 @@
 package gitinfo
 
+import "sync"
+
 type GitInfo struct {
-	Remotes Remotes
+	Dir           string // repository top-level directory, empty when unknown
+	Remotes       Remotes
+	ResolveBranch func() string // resolves current branch, nil when unknown
+
+	branchOnce sync.Once
+	branch     string
+}
+
+// Branch returns current branch, empty when unknown (e.g. detached HEAD); it is resolved on first use only, as few rules need it
+func (gi *GitInfo) Branch() string {
+	gi.branchOnce.Do(func() {
+		if gi.ResolveBranch != nil {
+			gi.branch = gi.ResolveBranch()
+		}
+	})
+	return gi.branch
 }
 
 type Remotes []*Remote
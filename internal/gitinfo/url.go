@@ -0,0 +1,88 @@
+package gitinfo
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+type RemoteURL struct {
+	Scheme string
+	User   string
+	Host   string
+	Port   string
+	Owner  string // all path segments except the last one, may contain nested groups (e.g. GitLab subgroups)
+	Name   string // last path segment without .git suffix
+}
+
+func ParseRemoteURL(raw string) (*RemoteURL, error) {
+	if raw == "" {
+		return nil, errors.New("empty remote url")
+	}
+	ru := &RemoteURL{}
+	p := ""
+	switch {
+	case strings.Contains(raw, "://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		ru.Scheme = strings.ToLower(u.Scheme)
+		if ru.Scheme == "git+ssh" || ru.Scheme == "ssh+git" { // aliases accepted by git
+			ru.Scheme = "ssh"
+		}
+		ru.User = u.User.Username()
+		ru.Host = strings.ToLower(u.Hostname())
+		ru.Port = u.Port()
+		p = u.Path
+	case isScpLike(raw):
+		userHost, path, _ := strings.Cut(raw, ":")
+		ru.Scheme = "ssh"
+		if user, host, found := strings.Cut(userHost, "@"); found {
+			ru.User, ru.Host = user, strings.ToLower(host)
+		} else {
+			ru.Host = strings.ToLower(userHost)
+		}
+		p = path
+	default:
+		ru.Scheme = "file"
+		p = strings.ReplaceAll(raw, "\\", "/")
+	}
+
+	p = strings.Trim(p, "/")
+	p = strings.TrimSuffix(p, ".git")
+	p = strings.TrimRight(p, "/")
+	if idx := strings.LastIndex(p, "/"); idx >= 0 {
+		ru.Owner, ru.Name = p[:idx], p[idx+1:]
+	} else {
+		ru.Name = p
+	}
+	return ru, nil
+}
+
+// EffectivePort returns port of the url, or default port of its scheme when port is not given explicitly
+func (ru *RemoteURL) EffectivePort() string {
+	if ru.Port != "" {
+		return ru.Port
+	}
+	return defaultPorts[ru.Scheme]
+}
+
+var defaultPorts = map[string]string{
+	"ssh":   "22",
+	"git":   "9418",
+	"http":  "80",
+	"https": "443",
+}
+
+// scp-like syntax is recognized by git only when there is no slash before the first colon
+func isScpLike(raw string) bool {
+	colon := strings.Index(raw, ":")
+	if colon <= 0 {
+		return false
+	}
+	if colon == 1 && len(raw) > 2 && (raw[2] == '\\' || raw[2] == '/') {
+		return false // windows drive letter
+	}
+	return !strings.Contains(raw[:colon], "/")
+}
@@ -0,0 +1,111 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+	"github.com/daishe/gitidentity/internal/logging"
+)
+
+var (
+	celEnvOnce = sync.OnceValues(newCelEnv)
+
+	celProgramsMu sync.Mutex
+	celPrograms   = map[string]cel.Program{} // compiled programs by expression, so that every expression is compiled once
+)
+
+func newCelEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("remotes", cel.ListType(cel.MapType(cel.StringType, cel.StringType))),
+		cel.Variable("env", cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable("path", cel.StringType),
+		cel.Variable("branch", cel.StringType),
+		cel.Variable("os", cel.StringType),
+	)
+}
+
+// compileExpression compiles and type-checks expression, compiled programs are cached
+func compileExpression(expr string) (cel.Program, error) {
+	celProgramsMu.Lock()
+	defer celProgramsMu.Unlock()
+	if prg, ok := celPrograms[expr]; ok {
+		return prg, nil
+	}
+	env, err := celEnvOnce()
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(expr)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("expression must evaluate to bool, got %s", ast.OutputType())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	logging.Log.Printf("compiled expression %q", expr)
+	celPrograms[expr] = prg
+	return prg, nil
+}
+
+// validateExpressions compiles all expressions of identity rules
+func validateExpressions(i *configv2.Identity) error {
+	for _, ml := range i.GetAutoApplyWhen() {
+		for _, m := range ml.GetMatch() {
+			if _, ok := m.GetSubject().(*configv2.Match_Expression); !ok {
+				continue
+			}
+			if _, err := compileExpression(m.GetExpression().GetExpression()); err != nil {
+				return fmt.Errorf("identity %q: compiling expression %q: %w", IdentityAsString(i), m.GetExpression().GetExpression(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func matchExpression(ctx context.Context, m *configv2.MatchExpression, info *gitinfo.GitInfo) (bool, Bindings, error) {
+	prg, err := compileExpression(m.GetExpression())
+	if err != nil {
+		return false, nil, fmt.Errorf("compiling expression %q: %w", m.GetExpression(), err)
+	}
+	out, _, err := prg.ContextEval(ctx, expressionVariables(info))
+	if err != nil {
+		return false, nil, fmt.Errorf("evaluating expression %q: %w", m.GetExpression(), err)
+	}
+	verdict, ok := out.Value().(bool)
+	if !ok {
+		return false, nil, fmt.Errorf("evaluating expression %q: result is not bool", m.GetExpression())
+	}
+	return verdict, nil, nil
+}
+
+func expressionVariables(info *gitinfo.GitInfo) map[string]any {
+	remotes := make([]map[string]string, 0, len(info.Remotes))
+	for _, r := range info.Remotes {
+		remotes = append(remotes, map[string]string{"name": r.Name, "url": r.Url})
+	}
+	env := map[string]string{}
+	for _, e := range os.Environ() {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			env[k] = v
+		}
+	}
+	return map[string]any{
+		"remotes": remotes,
+		"env":     env,
+		"path":    info.Dir,
+		"branch":  info.Branch(),
+		"os":      runtime.GOOS,
+	}
+}
@@ -0,0 +1,207 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+)
+
+var (
+	captureReferenceRegexp  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	templateReferenceRegexp = regexp.MustCompile(`\$\{([^{}]*)\}`) // only braced references are expanded, so that bare dollar signs in values are kept as is
+)
+
+// UsesTemplates reports if applied values of identity may differ from values stored in configuration
+func UsesTemplates(i *configv2.Identity) bool {
+	return i.GetExpandValues() || len(capturedReferences(i)) > 0
+}
+
+// captureNames returns names of capture groups of regexp conditions in identity rules
+func captureNames(i *configv2.Identity) map[string]bool {
+	names := map[string]bool{}
+	for _, ml := range i.GetAutoApplyWhen() {
+		for _, m := range ml.GetMatch() {
+			for _, c := range matchConditions(m) {
+				if c.GetMode() != configv2.ConditionMode_CONDITION_MODE_REGEXP {
+					continue
+				}
+				r, err := regexp.Compile(c.GetValue())
+				if err != nil {
+					continue
+				}
+				for _, name := range r.SubexpNames() {
+					if name != "" {
+						names[name] = true
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func matchConditions(m *configv2.Match) []*configv2.Condition {
+	switch s := m.GetSubject().(type) {
+	case *configv2.Match_Env:
+		return []*configv2.Condition{s.Env.GetTo()}
+	case *configv2.Match_Remote:
+		return []*configv2.Condition{s.Remote.GetName(), s.Remote.GetUrl()}
+	case *configv2.Match_Command:
+		return []*configv2.Condition{s.Command.GetOutput()}
+	case *configv2.Match_ShellScript:
+		return []*configv2.Condition{s.ShellScript.GetOutput()}
+	case *configv2.Match_Location:
+		return []*configv2.Condition{s.Location.GetPath()}
+	case *configv2.Match_Repository:
+		return []*configv2.Condition{s.Repository.GetRemoteName(), s.Repository.GetScheme(), s.Repository.GetHost(), s.Repository.GetPort(), s.Repository.GetOwner(), s.Repository.GetName()}
+	}
+	return nil
+}
+
+// capturedReferences returns sorted names of capture groups referenced in identity values
+func capturedReferences(i *configv2.Identity) []string {
+	names := captureNames(i)
+	if len(names) == 0 {
+		return nil
+	}
+	refs := []string(nil)
+	for _, v := range i.GetValues() {
+		for _, m := range captureReferenceRegexp.FindAllStringSubmatch(v, -1) {
+			if names[m[1]] && !slices.Contains(refs, m[1]) {
+				refs = append(refs, m[1])
+			}
+		}
+	}
+	slices.Sort(refs)
+	return refs
+}
+
+func referencesCapture(v string, names map[string]bool) bool {
+	for _, m := range captureReferenceRegexp.FindAllStringSubmatch(v, -1) {
+		if names[m[1]] {
+			return true
+		}
+	}
+	return false
+}
+
+// expandedValues returns identity values with captured values substituted and templates expanded, when identity opts in for expansion; captured values are only available when identity was selected automatically
+func expandedValues(i *configv2.Identity, info *gitinfo.GitInfo, bindings Bindings, autoSelected bool) (map[string]string, error) {
+	refs := capturedReferences(i)
+	if !i.GetExpandValues() && len(refs) == 0 {
+		return i.GetValues(), nil
+	}
+	if len(refs) > 0 && !autoSelected {
+		return nil, fmt.Errorf("identity %q uses values captured by its auto apply rules (%s), it can only be applied automatically", IdentityAsString(i), strings.Join(refs, ", "))
+	}
+	if info == nil {
+		info = &gitinfo.GitInfo{}
+	}
+	names := captureNames(i)
+	e := &expander{info: info}
+	values := make(map[string]string, len(i.GetValues()))
+	for k, v := range i.GetValues() {
+		missing := ""
+		v = captureReferenceRegexp.ReplaceAllStringFunc(v, func(ref string) string {
+			name := captureReferenceRegexp.FindStringSubmatch(ref)[1]
+			if !names[name] {
+				return ref
+			}
+			captured, ok := bindings[name]
+			if !ok && missing == "" {
+				missing = name
+			}
+			return captured
+		})
+		if missing != "" {
+			return nil, fmt.Errorf("identity %q: value of %q: value captured as %q is not available from the matching rule", IdentityAsString(i), k, missing)
+		}
+		if !i.GetExpandValues() {
+			values[k] = v
+			continue
+		}
+		expanded, err := e.expand(v)
+		if err != nil {
+			return nil, fmt.Errorf("identity %q: expanding value of %q: %w", IdentityAsString(i), k, err)
+		}
+		values[k] = expanded
+	}
+	return values, nil
+}
+
+type expander struct {
+	info   *gitinfo.GitInfo
+	remote *gitinfo.RemoteURL
+}
+
+func (e *expander) expand(v string) (string, error) {
+	if v == "~" || strings.HasPrefix(v, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		v = filepath.Join(home, v[1:])
+	}
+	firstErr := error(nil)
+	expanded := templateReferenceRegexp.ReplaceAllStringFunc(v, func(ref string) string {
+		r, err := e.lookup(templateReferenceRegexp.FindStringSubmatch(ref)[1])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return r
+	})
+	return expanded, firstErr
+}
+
+func (e *expander) lookup(name string) (string, error) {
+	switch name {
+	case "repo.dir":
+		if e.info.Dir == "" {
+			return "", errors.New("repository top-level directory is unknown")
+		}
+		return e.info.Dir, nil
+	case "remote.host", "remote.owner", "remote.repo":
+		ru, err := e.remoteURL()
+		if err != nil {
+			return "", err
+		}
+		switch name {
+		case "remote.host":
+			return ru.Host, nil
+		case "remote.owner":
+			return ru.Owner, nil
+		}
+		return ru.Name, nil
+	}
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not defined", name)
+	}
+	return v, nil
+}
+
+func (e *expander) remoteURL() (*gitinfo.RemoteURL, error) {
+	if e.remote != nil {
+		return e.remote, nil
+	}
+	r := e.info.Remotes.ByName("origin")
+	if r == nil && len(e.info.Remotes) > 0 {
+		r = e.info.Remotes[0]
+	}
+	if r == nil {
+		return nil, errors.New("repository has no remotes")
+	}
+	ru, err := gitinfo.ParseRemoteURL(r.Url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url of remote %q: %w", r.Name, err)
+	}
+	e.remote = ru
+	return ru, nil
+}
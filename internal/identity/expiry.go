@@ -0,0 +1,59 @@
+package identity
+
+import (
+	"fmt"
+	"time"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+)
+
+// ExpiryWarningPeriod is how long before expiration loading configuration warns about expiring identities
+const ExpiryWarningPeriod = 14 * 24 * time.Hour
+
+func expiresAt(i *configv2.Identity) (time.Time, bool, error) {
+	if i.GetExpiresAt() == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, i.GetExpiresAt())
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("identity %q: invalid expiration time: %w", IdentityAsString(i), err)
+	}
+	return t, true, nil
+}
+
+// IsExpired reports if identity expiration time has passed
+func IsExpired(i *configv2.Identity, now time.Time) bool {
+	t, has, err := expiresAt(i)
+	if err != nil {
+		return true // invalid expiration time never makes identity usable
+	}
+	return has && !now.Before(t)
+}
+
+// IsActive reports if identity is neither disabled nor expired
+func IsActive(i *configv2.Identity, now time.Time) bool {
+	return !i.GetDisabled() && !IsExpired(i, now)
+}
+
+// ActiveIdentities returns identities that are neither disabled nor expired
+func ActiveIdentities(is []*configv2.Identity, now time.Time) []*configv2.Identity {
+	active := make([]*configv2.Identity, 0, len(is))
+	for _, i := range is {
+		if IsActive(i, now) {
+			active = append(active, i)
+		}
+	}
+	return active
+}
+
+func expiryWarnings(is []*configv2.Identity, now time.Time) []string {
+	warnings := []string(nil)
+	for _, i := range is {
+		t, has, _ := expiresAt(i)
+		if !has || i.GetDisabled() || !now.Before(t) || t.Sub(now) > ExpiryWarningPeriod {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("identity %q expires at %s", IdentityAsString(i), t.Format(time.RFC3339)))
+	}
+	return warnings
+}
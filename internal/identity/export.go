@@ -0,0 +1,214 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/runcmd"
+)
+
+// ExportableIdentities returns identities that can be exported as native git config, along with reasons for skipping the remaining ones
+func ExportableIdentities(is []*configv2.Identity, now time.Time) ([]*configv2.Identity, []string) {
+	exportable, skipped := make([]*configv2.Identity, 0, len(is)), []string(nil)
+	for _, i := range is {
+		switch {
+		case i.GetDisabled():
+			skipped = append(skipped, fmt.Sprintf("identity %s: skipped as it is disabled", IdentityAsString(i)))
+		case IsExpired(i, now):
+			skipped = append(skipped, fmt.Sprintf("identity %s: skipped as it is expired", IdentityAsString(i)))
+		case UsesTemplates(i):
+			skipped = append(skipped, fmt.Sprintf("identity %s: skipped as its values are expanded or captured by auto apply rules only when applied by gitidentity", IdentityAsString(i)))
+		default:
+			exportable = append(exportable, i)
+		}
+	}
+	return exportable, skipped
+}
+
+type GitConfigExport struct {
+	Identity   *configv2.Identity
+	File       string   // include file with identity values
+	Conditions []string // includeIf conditions including the file
+	Skipped    []string // rules and values that cannot be expressed natively
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func ExportAsGitConfig(ctx context.Context, is []*configv2.Identity, dir string) (string, []*GitConfigExport, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, fmt.Errorf("making export directory: %w", err)
+	}
+	snippet := filepath.Join(dir, "includes.gitconfig")
+	if err := removeIfExists(snippet); err != nil {
+		return "", nil, err
+	}
+
+	exports := make([]*GitConfigExport, 0, len(is))
+	usedNames := map[string]bool{}
+	for _, i := range is {
+		e := &GitConfigExport{Identity: i, File: filepath.Join(dir, exportFileName(i, usedNames))}
+		if err := writeIdentityGitConfig(ctx, i, e.File); err != nil {
+			return "", nil, fmt.Errorf("exporting identity %q: %w", IdentityAsString(i), err)
+		}
+		for _, k := range slices.Sorted(maps.Keys(i.GetValuesFrom())) {
+			e.Skipped = append(e.Skipped, fmt.Sprintf("value of %q: resolved from command only when applied by gitidentity", k))
+		}
+		for idx, ml := range i.GetAutoApplyWhen() {
+			cond, err := matchListAsIncludeIfCondition(ml)
+			if err != nil {
+				e.Skipped = append(e.Skipped, fmt.Sprintf("rule #%d: %v", idx+1, err))
+				continue
+			}
+			e.Conditions = append(e.Conditions, cond)
+		}
+		exports = append(exports, e)
+	}
+
+	// git applies the last matching include, while gitidentity applies the first matching identity, so includes are written in reverse auto apply order
+	exportOf := make(map[*configv2.Identity]*GitConfigExport, len(exports))
+	for _, e := range exports {
+		exportOf[e.Identity] = e
+	}
+	ordered := AutoApplyOrder(is)
+	slices.Reverse(ordered)
+	for _, i := range ordered {
+		e := exportOf[i]
+		for _, cond := range e.Conditions {
+			if err := runcmd.AddGitConfigFileValue(ctx, snippet, "includeIf."+cond+".path", filepath.ToSlash(e.File)); err != nil {
+				return "", nil, fmt.Errorf("exporting identity %q: %w", IdentityAsString(i), err)
+			}
+		}
+	}
+	return snippet, exports, nil
+}
+
+func exportFileName(i *configv2.Identity, used map[string]bool) string {
+	base := strings.Trim(unsafeFileNameChars.ReplaceAllString(IdentityAsString(i), "-"), "-")
+	if base == "" {
+		base = "identity"
+	}
+	name := base
+	for n := 2; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	used[name] = true
+	return name + ".gitconfig"
+}
+
+// writeIdentityGitConfig writes identity values to file, values are written as stored in configuration, so identities using templates must not be exported
+func writeIdentityGitConfig(ctx context.Context, i *configv2.Identity, file string) error {
+	if err := removeIfExists(file); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(i.GetValues()))
+	for k, v := range i.GetValues() {
+		if v != "" { // empty value means unset, there is nothing to include
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := runcmd.AddGitConfigFileValue(ctx, file, k, i.GetValues()[k]); err != nil {
+			return err
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(i.GetMultiValues())) {
+		for _, v := range i.GetMultiValues()[k].GetValues() {
+			if err := runcmd.AddGitConfigFileValue(ctx, file, k, v); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range i.GetUnsetValues() {
+		if err := runcmd.AddGitConfigFileValue(ctx, file, k, ""); err != nil { // empty value overrides value from other scopes
+			return err
+		}
+	}
+	return nil
+}
+
+func removeIfExists(file string) error {
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func matchListAsIncludeIfCondition(ml *configv2.MatchList) (string, error) {
+	if len(ml.GetMatch()) != 1 {
+		return "", errors.New("only rules with exactly one match can be expressed natively")
+	}
+	switch s := ml.GetMatch()[0].GetSubject().(type) {
+	case *configv2.Match_Remote:
+		return remoteAsIncludeIfCondition(s.Remote)
+	case *configv2.Match_Location:
+		return locationAsIncludeIfCondition(s.Location)
+	case *configv2.Match_Env:
+		return "", errors.New("environment variable rules cannot be expressed natively")
+	case *configv2.Match_Command:
+		return "", errors.New("command rules cannot be expressed natively")
+	case *configv2.Match_ShellScript:
+		return "", errors.New("shell script rules cannot be expressed natively")
+	case *configv2.Match_Expression:
+		return "", errors.New("expression rules cannot be expressed natively")
+	case *configv2.Match_Repository:
+		return "", errors.New("repository rules cannot be expressed natively, as they do not depend on remote url syntax")
+	}
+	return "", errors.New("rule cannot be expressed natively")
+}
+
+func remoteAsIncludeIfCondition(m *configv2.MatchRemote) (string, error) {
+	if n := m.GetName(); n.GetNegate() || n.GetValue() != "" {
+		return "", errors.New("remote name conditions cannot be expressed natively")
+	}
+	c := m.GetUrl()
+	if c.GetNegate() {
+		return "", errors.New("negated remote url conditions cannot be expressed natively")
+	}
+	switch c.GetMode() {
+	case configv2.ConditionMode_CONDITION_MODE_PREFIX:
+		return "hasconfig:remote.*.url:" + escapeGlob(c.GetValue()) + "**", nil
+	case configv2.ConditionMode_CONDITION_MODE_FULL:
+		return "hasconfig:remote.*.url:" + escapeGlob(c.GetValue()), nil
+	case configv2.ConditionMode_CONDITION_MODE_SHELL_PATTERN:
+		return "hasconfig:remote.*.url:" + c.GetValue(), nil
+	default:
+		return "", fmt.Errorf("remote url conditions in mode %s cannot be expressed natively", c.GetMode())
+	}
+}
+
+func locationAsIncludeIfCondition(m *configv2.MatchLocation) (string, error) {
+	c := m.GetPath()
+	if c.GetNegate() {
+		return "", errors.New("negated repository location conditions cannot be expressed natively")
+	}
+	switch c.GetMode() {
+	case configv2.ConditionMode_CONDITION_MODE_PREFIX:
+		if !strings.HasSuffix(c.GetValue(), "/") {
+			return "", errors.New("repository location prefix conditions not ending with a slash cannot be expressed natively")
+		}
+		return "gitdir:" + escapeGlob(c.GetValue()), nil
+	case configv2.ConditionMode_CONDITION_MODE_FULL:
+		return "gitdir:" + escapeGlob(c.GetValue()) + "/.git", nil
+	default:
+		return "", fmt.Errorf("repository location conditions in mode %s cannot be expressed natively", c.GetMode())
+	}
+}
+
+func escapeGlob(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`).Replace(s)
+}
@@ -0,0 +1,314 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+	"github.com/daishe/gitidentity/internal/logging"
+	"github.com/daishe/gitidentity/internal/runcmd"
+)
+
+func RemoteURLsAsAutoApplyRules(info *gitinfo.GitInfo) []*configv2.MatchList {
+	seen := map[string]bool{}
+	rules := []*configv2.MatchList(nil)
+	for _, r := range info.Remotes {
+		if seen[r.Url] {
+			continue
+		}
+		seen[r.Url] = true
+		rules = append(rules, remoteURLRule(configv2.ConditionMode_CONDITION_MODE_FULL, r.Url))
+	}
+	return rules
+}
+
+func remoteURLRule(mode configv2.ConditionMode, url string) *configv2.MatchList {
+	return &configv2.MatchList{
+		Match: []*configv2.Match{
+			{
+				Subject: &configv2.Match_Remote{
+					Remote: &configv2.MatchRemote{
+						Url: &configv2.Condition{Mode: mode, Value: url},
+					},
+				},
+			},
+		},
+	}
+}
+
+type Proposal struct {
+	Identity *configv2.Identity
+	Origins  []string // where the identity was found, e.g. remote hosts and owners it is used with
+}
+
+func ScanRepositories(ctx context.Context, root string) ([]*Proposal, error) {
+	repos, err := findRepositories(root)
+	if err != nil {
+		return nil, err
+	}
+
+	proposals := []*Proposal(nil)
+	byNameAndEmail := map[[2]string]*Proposal{}
+	for _, repo := range repos {
+		repoCtx := runcmd.WithDir(ctx, repo)
+		i, err := RepositoryIdentity(repoCtx)
+		if err != nil {
+			logging.Log.Printf("scanning repository %q failed: %v", repo, err)
+			continue
+		}
+		key := [2]string{userName(i), userEmail(i)}
+		if key == [2]string{} {
+			continue // no local identity
+		}
+		gi, err := runcmd.GitInfoFromDir(repoCtx)
+		if err != nil {
+			logging.Log.Printf("scanning repository %q remotes failed: %v", repo, err)
+			continue
+		}
+
+		p := byNameAndEmail[key]
+		if p == nil {
+			i.Identifier = ""
+			for k, v := range i.GetValues() {
+				if v == "" {
+					delete(i.Values, k)
+				}
+			}
+			p = &Proposal{Identity: i}
+			byNameAndEmail[key] = p
+			proposals = append(proposals, p)
+		}
+		for _, r := range gi.Remotes {
+			p.addRemote(r.Url)
+		}
+	}
+	return proposals, nil
+}
+
+func (p *Proposal) addRemote(rawURL string) {
+	prefix := remoteOwnerPrefix(rawURL)
+	for _, ml := range p.Identity.GetAutoApplyWhen() {
+		if ml.GetMatch()[0].GetRemote().GetUrl().GetValue() == prefix {
+			return
+		}
+	}
+	p.Identity.AutoApplyWhen = append(p.Identity.AutoApplyWhen, remoteURLRule(configv2.ConditionMode_CONDITION_MODE_PREFIX, prefix))
+	if u, err := gitinfo.ParseRemoteURL(rawURL); err == nil {
+		p.Origins = append(p.Origins, path.Join(u.Host, u.Owner))
+	}
+}
+
+// remoteOwnerPrefix strips repository name from the url, so that the result matches all repositories of the owner
+func remoteOwnerPrefix(rawURL string) string {
+	trimmed := strings.TrimRight(rawURL, "/")
+	idx := strings.LastIndexAny(trimmed, "/:")
+	if idx < 0 {
+		return rawURL
+	}
+	return trimmed[:idx+1]
+}
+
+func findRepositories(root string) ([]string, error) {
+	repos := []string(nil)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			logging.Log.Printf("skipping %q: %v", p, err)
+			return nil
+		}
+		if d.Name() != ".git" {
+			return nil
+		}
+		repos = append(repos, filepath.Dir(p))
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return repos, err
+}
+
+func IdentityWithNameAndEmail(is []*configv2.Identity, i *configv2.Identity) *configv2.Identity {
+	for _, candidate := range is {
+		if userName(candidate) == userName(i) && userEmail(candidate) == userEmail(i) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func IncludeIfProposals(ctx context.Context) ([]*Proposal, []string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := runcmd.ListGitConfigValues(ctx, runcmd.FlagLocalOff, runcmd.FlagGlobalOn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proposals := []*Proposal(nil)
+	byPath := map[string]*Proposal{}
+	skipped := []string(nil)
+	userKeys := keyPatternRegexp("user.*")
+	for _, e := range entries {
+		cond, isIncludeIf := includeIfCondition(e.Key)
+		if !isIncludeIf {
+			continue
+		}
+		directive := fmt.Sprintf("includeIf %q", cond)
+		rule, err := includeIfConditionAsRule(cond, home, configDir(e.Origin, home))
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", directive, err))
+			continue
+		}
+
+		includePath := expandHome(e.Value, home)
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(configDir(e.Origin, home), includePath) // relative to the including file
+		}
+		p := byPath[includePath]
+		if p == nil {
+			values, err := runcmd.ListGitConfigFileValues(ctx, includePath)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s: %v", directive, err))
+				continue
+			}
+			i := &configv2.Identity{Values: map[string]string{}}
+			for _, v := range values {
+				if userKeys.MatchString(v.Key) {
+					i.Values[v.Key] = v.Value
+				}
+			}
+			if len(i.GetValues()) == 0 {
+				skipped = append(skipped, fmt.Sprintf("%s: no user values in %q", directive, includePath))
+				continue
+			}
+			p = &Proposal{Identity: i}
+			byPath[includePath] = p
+			proposals = append(proposals, p)
+		}
+		p.Identity.AutoApplyWhen = append(p.Identity.AutoApplyWhen, rule)
+		p.Origins = append(p.Origins, directive)
+	}
+	return proposals, skipped, nil
+}
+
+func includeIfCondition(key string) (string, bool) {
+	rest, found := strings.CutPrefix(key, "includeif.")
+	if !found {
+		return "", false
+	}
+	return strings.CutSuffix(rest, ".path")
+}
+
+func includeIfConditionAsRule(cond, home, dir string) (*configv2.MatchList, error) {
+	switch {
+	case strings.HasPrefix(cond, "gitdir:"):
+		return gitDirRule(strings.TrimPrefix(cond, "gitdir:"), home, dir, false), nil
+	case strings.HasPrefix(cond, "gitdir/i:"):
+		return gitDirRule(strings.TrimPrefix(cond, "gitdir/i:"), home, dir, true), nil
+	case strings.HasPrefix(cond, "hasconfig:remote.*.url:"):
+		return remoteURLPatternRule(strings.TrimPrefix(cond, "hasconfig:remote.*.url:")), nil
+	}
+	return nil, errors.New("unsupported condition")
+}
+
+// gitDirRule converts pattern of includeIf gitdir condition (matched by git against .git directory) into rule matching repository top-level directory
+func gitDirRule(pattern, home, dir string, caseInsensitive bool) *configv2.MatchList {
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		pattern = filepath.ToSlash(home) + pattern[1:]
+	case strings.HasPrefix(pattern, "./"):
+		pattern = filepath.ToSlash(dir) + pattern[1:] // relative to the including file
+	case !strings.HasPrefix(pattern, "/") && !filepath.IsAbs(pattern):
+		pattern = "**/" + pattern
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	pattern = strings.TrimSuffix(pattern, "/.git")
+
+	c := &configv2.Condition{}
+	switch base, isPrefix := strings.CutSuffix(pattern, "**"); {
+	case caseInsensitive:
+		c.Mode, c.Value = configv2.ConditionMode_CONDITION_MODE_REGEXP, "(?i)"+globToRegexp(pattern, true)
+	case isPrefix && strings.HasSuffix(base, "/") && !strings.ContainsAny(base, "*?["):
+		c.Mode, c.Value = configv2.ConditionMode_CONDITION_MODE_PREFIX, base
+	case !strings.ContainsAny(pattern, "*?["):
+		c.Mode, c.Value = configv2.ConditionMode_CONDITION_MODE_FULL, pattern
+	default:
+		c.Mode, c.Value = configv2.ConditionMode_CONDITION_MODE_REGEXP, globToRegexp(pattern, true)
+	}
+	return &configv2.MatchList{
+		Match: []*configv2.Match{{Subject: &configv2.Match_Location{Location: &configv2.MatchLocation{Path: c}}}},
+	}
+}
+
+// remoteURLPatternRule converts pattern of includeIf hasconfig:remote.*.url condition (matched by git with wildmatch, where "**" also matches slashes) into rule matching remote url
+func remoteURLPatternRule(pattern string) *configv2.MatchList {
+	switch base, isPrefix := strings.CutSuffix(pattern, "**"); {
+	case isPrefix && !strings.ContainsAny(base, `*?[\`):
+		return remoteURLRule(configv2.ConditionMode_CONDITION_MODE_PREFIX, base)
+	case !strings.ContainsAny(pattern, `*?[\`):
+		return remoteURLRule(configv2.ConditionMode_CONDITION_MODE_FULL, pattern)
+	}
+	return remoteURLRule(configv2.ConditionMode_CONDITION_MODE_REGEXP, globToRegexp(pattern, false))
+}
+
+// globToRegexp converts wildmatch pattern into regexp, with optionalTrailing trailing "/**" also matches nothing, for paths matched without trailing .git directory
+func globToRegexp(pattern string, optionalTrailing bool) string {
+	b := strings.Builder{}
+	b.WriteString("^")
+	for idx := 0; idx < len(pattern); idx++ {
+		switch rest := pattern[idx:]; {
+		case rest[0] == '\\' && len(rest) > 1: // escaped character
+			b.WriteString(regexp.QuoteMeta(rest[1:2]))
+			idx++
+		case rest == "/**" && optionalTrailing: // matched path lacks trailing .git directory, so allow no more segments
+			b.WriteString("(/.*)?")
+			idx += 2
+		case strings.HasPrefix(rest, "**/"):
+			b.WriteString("(.*/)?")
+			idx += 2
+		case strings.HasPrefix(rest, "**"):
+			b.WriteString(".*")
+			idx++
+		case rest[0] == '*':
+			b.WriteString("[^/]*")
+		case rest[0] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+func configDir(origin, home string) string {
+	if origin == "" {
+		return home
+	}
+	return filepath.Dir(origin)
+}
+
+func expandHome(p, home string) string {
+	if p == "~" {
+		return home
+	}
+	if rest, found := strings.CutPrefix(p, "~/"); found {
+		return filepath.Join(home, rest)
+	}
+	return p
+}
@@ -2,15 +2,19 @@ package identity
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path"
 	"regexp"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
 	"github.com/daishe/gitidentity/internal/gitinfo"
@@ -39,12 +43,35 @@ func IdentitiesAsStrings(is []*configv2.Identity) []string {
 	return ss
 }
 
+// FilterByTags returns identities having all of the tags
+func FilterByTags(is []*configv2.Identity, tags []string) []*configv2.Identity {
+	if len(tags) == 0 {
+		return is
+	}
+	filtered := make([]*configv2.Identity, 0, len(is))
+	for _, i := range is {
+		if !slices.ContainsFunc(tags, func(t string) bool { return !slices.Contains(i.GetTags(), t) }) {
+			filtered = append(filtered, i)
+		}
+	}
+	return filtered
+}
+
 func SortIdentities(is []*configv2.Identity) {
 	sort.Slice(is, func(i, j int) bool {
 		return IdentityAsString(is[i]) < IdentityAsString(is[j])
 	})
 }
 
+// AutoApplyOrder returns identities in order used for auto matching: by descending priority, keeping configuration order for equal priorities
+func AutoApplyOrder(is []*configv2.Identity) []*configv2.Identity {
+	ordered := slices.Clone(is)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].GetPriority() > ordered[j].GetPriority()
+	})
+	return ordered
+}
+
 func valueOf(i *configv2.Identity, key string) string {
 	v := i.GetValues()
 	if v == nil {
@@ -61,20 +88,57 @@ func userEmail(i *configv2.Identity) string {
 	return valueOf(i, runcmd.GitEmailKey)
 }
 
-func gitNameAndEmailAsIdentity(ctx context.Context, local runcmd.FlagLocalState, global runcmd.FlagGlobalState) (i *configv2.Identity, err error) {
-	i = &configv2.Identity{Values: make(map[string]string, 2)}
-	i.Values[runcmd.GitNameKey], _, err = runcmd.GetGitConfigValue(ctx, runcmd.GitNameKey, local, global)
+func gitNameAndEmailAsIdentity(ctx context.Context, local runcmd.FlagLocalState, global runcmd.FlagGlobalState) (*configv2.Identity, error) {
+	return gitConfigAsIdentity(ctx, []string{runcmd.GitNameKey, runcmd.GitEmailKey}, local, global)
+}
+
+func gitConfigAsIdentity(ctx context.Context, keyPatterns []string, local runcmd.FlagLocalState, global runcmd.FlagGlobalState) (*configv2.Identity, error) {
+	entries, err := runcmd.ListGitConfigValues(ctx, local, global)
 	if err != nil {
 		return nil, err
 	}
-	i.Values[runcmd.GitEmailKey], _, err = runcmd.GetGitConfigValue(ctx, runcmd.GitEmailKey, local, global)
-	if err != nil {
-		return nil, err
+	matchers := make([]*regexp.Regexp, len(keyPatterns))
+	for idx, p := range keyPatterns {
+		matchers[idx] = keyPatternRegexp(p)
+	}
+
+	i := &configv2.Identity{Values: make(map[string]string, len(keyPatterns))}
+	for _, p := range keyPatterns {
+		if !isKeyPattern(p) {
+			i.Values[p] = "" // plain keys are always present, even if unset
+		}
+	}
+	for _, e := range entries { // later entries take precedence, the same as in git
+		if strings.HasPrefix(e.Key, "gitidentity.") {
+			continue
+		}
+		for idx, m := range matchers {
+			if !m.MatchString(e.Key) {
+				continue
+			}
+			if isKeyPattern(keyPatterns[idx]) {
+				i.Values[e.Key] = e.Value
+			} else {
+				i.Values[keyPatterns[idx]] = e.Value // keep key spelling, as git normalizes case of section and name
+			}
+			break
+		}
 	}
 	i.Identifier = IdentityAsString(i)
 	return i, nil
 }
 
+func isKeyPattern(key string) bool {
+	return strings.ContainsAny(key, "*?")
+}
+
+func keyPatternRegexp(pattern string) *regexp.Regexp {
+	r := regexp.QuoteMeta(pattern)
+	r = strings.ReplaceAll(r, `\*`, ".*")
+	r = strings.ReplaceAll(r, `\?`, ".")
+	return regexp.MustCompile("(?i)^" + r + "$")
+}
+
 func CurrentIdentity(ctx context.Context, includeGlobal bool) (*configv2.Identity, error) {
 	last, has, err := runcmd.GetGitConfigValue(ctx, runcmd.GitLastAppliedKey, runcmd.FlagLocalOn, runcmd.FlagGlobalOff)
 	if err != nil {
@@ -86,14 +150,9 @@ func CurrentIdentity(ctx context.Context, includeGlobal bool) (*configv2.Identit
 		}
 		return nil, ErrNoCurrentIdentity
 	}
-
-	a := &anypb.Any{}
-	if err := protojson.Unmarshal([]byte(last), a); err != nil {
-		return nil, fmt.Errorf("failed to unmarshall value of %s config key", runcmd.GitLastAppliedKey)
-	}
-	i, err := unmarshallIdentityFromAny(a)
+	i, err := unmarshallLastAppliedIdentity(last)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshall value of %s config key: %w", runcmd.GitLastAppliedKey, err)
+		return nil, err
 	}
 
 	for field := range i.GetValues() {
@@ -102,6 +161,37 @@ func CurrentIdentity(ctx context.Context, includeGlobal bool) (*configv2.Identit
 			return nil, err
 		}
 	}
+	for field := range i.GetMultiValues() {
+		values, err := runcmd.GetAllGitConfigValues(ctx, field, runcmd.FlagLocalState(!includeGlobal), runcmd.FlagGlobalOff)
+		if err != nil {
+			return nil, err
+		}
+		i.MultiValues[field] = &configv2.ValueList{Values: values}
+	}
+	return i, nil
+}
+
+// LastAppliedIdentity returns identity as stored when it was applied, with values not expanded
+func LastAppliedIdentity(ctx context.Context) (*configv2.Identity, error) {
+	last, has, err := runcmd.GetGitConfigValue(ctx, runcmd.GitLastAppliedKey, runcmd.FlagLocalOn, runcmd.FlagGlobalOff)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrNoCurrentIdentity
+	}
+	return unmarshallLastAppliedIdentity(last)
+}
+
+func unmarshallLastAppliedIdentity(last string) (*configv2.Identity, error) {
+	a := &anypb.Any{}
+	if err := protojson.Unmarshal([]byte(last), a); err != nil {
+		return nil, fmt.Errorf("failed to unmarshall value of %s config key", runcmd.GitLastAppliedKey)
+	}
+	i, err := unmarshallIdentityFromAny(a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshall value of %s config key: %w", runcmd.GitLastAppliedKey, err)
+	}
 	return i, nil
 }
 
@@ -109,6 +199,14 @@ func GlobalIdentity(ctx context.Context) (*configv2.Identity, error) {
 	return gitNameAndEmailAsIdentity(ctx, runcmd.FlagLocalOff, runcmd.FlagGlobalOn)
 }
 
+func RepositoryIdentity(ctx context.Context) (*configv2.Identity, error) {
+	return gitNameAndEmailAsIdentity(ctx, runcmd.FlagLocalOn, runcmd.FlagGlobalOff)
+}
+
+func RepositoryIdentityFromKeys(ctx context.Context, keyPatterns []string) (*configv2.Identity, error) {
+	return gitConfigAsIdentity(ctx, keyPatterns, runcmd.FlagLocalOn, runcmd.FlagGlobalOff)
+}
+
 func unsetNameAndEmail(ctx context.Context) error {
 	if err := runcmd.SetGitConfigValue(ctx, runcmd.GitNameKey, ""); err != nil {
 		return err
@@ -119,6 +217,20 @@ func unsetNameAndEmail(ctx context.Context) error {
 	return nil
 }
 
+// appliedKeys returns all git config keys set when identity is applied
+func appliedKeys(i *configv2.Identity) []string {
+	keys := make([]string, 0, len(i.GetValues())+len(i.GetValuesFrom()))
+	for k := range i.GetValues() {
+		keys = append(keys, k)
+	}
+	for k := range i.GetValuesFrom() {
+		if _, ok := i.GetValues()[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
 func UnsetCurrentIdentity(ctx context.Context) error {
 	i, err := CurrentIdentity(ctx, false)
 	if errors.Is(err, ErrNoCurrentIdentity) {
@@ -128,27 +240,98 @@ func UnsetCurrentIdentity(ctx context.Context) error {
 		return err
 	}
 
-	for field := range i.GetValues() {
+	for _, field := range appliedKeys(i) {
 		if err := runcmd.SetGitConfigValue(ctx, field, ""); err != nil {
 			return err
 		}
 	}
+	if err := restorePreviousValues(ctx, overriddenKeys(i)); err != nil {
+		return err
+	}
 	if err := runcmd.SetGitConfigValue(ctx, runcmd.GitLastAppliedKey, ""); err != nil {
 		return err
 	}
 	return unsetNameAndEmail(ctx)
 }
 
-func ApplyIdentity(ctx context.Context, i *configv2.Identity) error {
-	logging.Log.Printf("applying identity %q to repository config", i.GetIdentifier())
-	if err := UnsetCurrentIdentity(ctx); err != nil {
+// overriddenKeys returns keys which values in repository config are replaced when identity is applied: unset values and multi-valued keys
+func overriddenKeys(i *configv2.Identity) []string {
+	keys := slices.Clone(i.GetUnsetValues())
+	for _, k := range slices.Sorted(maps.Keys(i.GetMultiValues())) {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// recordPreviousValues records values keys have in repository config, so that they can be restored once they are overridden
+func recordPreviousValues(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	previous := map[string][]string{}
+	for _, k := range keys {
+		values, err := runcmd.GetAllGitConfigValues(ctx, k, runcmd.FlagLocalOn, runcmd.FlagGlobalOff)
+		if err != nil {
+			return err
+		}
+		if len(values) > 0 {
+			previous[k] = values
+		}
+	}
+	if len(previous) > 0 {
+		b, err := json.Marshal(previous)
+		if err != nil {
+			return err
+		}
+		if err := runcmd.SetGitConfigValue(ctx, runcmd.GitPreviousValuesKey, string(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restorePreviousValues removes values of keys and restores values recorded before they were overridden
+func restorePreviousValues(ctx context.Context, keys []string) error {
+	recorded, has, err := runcmd.GetGitConfigValue(ctx, runcmd.GitPreviousValuesKey, runcmd.FlagLocalOn, runcmd.FlagGlobalOff)
+	if err != nil {
 		return err
 	}
+	previous := map[string][]string{}
+	if has {
+		if err := json.Unmarshal([]byte(recorded), &previous); err != nil {
+			return fmt.Errorf("failed to unmarshall value of %s config key: %w", runcmd.GitPreviousValuesKey, err)
+		}
+	}
+	for _, k := range keys {
+		if err := runcmd.UnsetAllGitConfigValues(ctx, k); err != nil {
+			return err
+		}
+		for _, v := range previous[k] {
+			if err := runcmd.AddGitConfigValue(ctx, k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return runcmd.SetGitConfigValue(ctx, runcmd.GitPreviousValuesKey, "")
+}
+
+// ApplyIdentity applies identity to repository config, autoSelected tells if identity was selected by its auto apply rules, which captured the bindings
+func ApplyIdentity(ctx context.Context, i *configv2.Identity, info *gitinfo.GitInfo, bindings Bindings, autoSelected bool) error {
+	logging.Log.Printf("applying identity %q to repository config", i.GetIdentifier())
 	if i == nil {
-		return nil
+		return UnsetCurrentIdentity(ctx)
 	}
 
 	i.Identifier = IdentityAsString(i)
+	values, err := resolvedValues(ctx, i, info, bindings, autoSelected) // resolved before unsetting current identity, so that failure does not leave repository half-applied
+	if err != nil {
+		return err
+	}
+	if err := UnsetCurrentIdentity(ctx); err != nil {
+		return err
+	}
 	a, err := marshallIdentityIntoAny(i)
 	if err != nil {
 		return err
@@ -156,161 +339,311 @@ func ApplyIdentity(ctx context.Context, i *configv2.Identity) error {
 	if err := runcmd.SetGitConfigValue(ctx, runcmd.GitLastAppliedKey, string(a)); err != nil {
 		return err
 	}
-	for key, value := range i.GetValues() {
+	if err := recordPreviousValues(ctx, overriddenKeys(i)); err != nil {
+		return err
+	}
+	for _, key := range i.GetUnsetValues() {
+		if err := runcmd.SetGitConfigEmptyValue(ctx, key); err != nil {
+			return err
+		}
+	}
+	for key, value := range values {
 		if err := runcmd.SetGitConfigValue(ctx, key, value); err != nil {
 			return err
 		}
 	}
+	for _, key := range slices.Sorted(maps.Keys(i.GetMultiValues())) {
+		if err := runcmd.UnsetAllGitConfigValues(ctx, key); err != nil {
+			return err
+		}
+		for _, value := range i.GetMultiValues()[key].GetValues() {
+			if err := runcmd.AddGitConfigValue(ctx, key, value); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-func ApplyIdentityAsArgs(ctx context.Context, i *configv2.Identity) ([]string, error) {
+// ApplyIdentityAsArgs returns git clone arguments applying the identity; values resolved from commands are returned separately, as they must not be passed on command line, where other processes can see them, and are to be applied with ApplyValues once repository is cloned
+func ApplyIdentityAsArgs(ctx context.Context, i *configv2.Identity, info *gitinfo.GitInfo, bindings Bindings, autoSelected bool) ([]string, map[string]string, error) {
 	logging.Log.Printf("applying identity %q as arguments", i.GetIdentifier())
 	i.Identifier = IdentityAsString(i)
+	values, err := resolvedValues(ctx, i, info, bindings, autoSelected)
+	if err != nil {
+		return nil, nil, err
+	}
 	a, err := marshallIdentityIntoAny(i)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	args := make([]string, 0, len(i.GetValues())+1)
+	args := make([]string, 0, len(values)+len(i.GetUnsetValues())+1)
 	args = append(args, fmt.Sprintf("--config=%s=%s", runcmd.GitLastAppliedKey, a))
-	for k, v := range i.GetValues() {
+	for _, k := range i.GetUnsetValues() {
+		args = append(args, fmt.Sprintf("--config=%s=", k))
+	}
+	secrets := map[string]string{}
+	for k, v := range values {
+		if _, fromCommand := i.GetValuesFrom()[k]; fromCommand {
+			secrets[k] = v
+			continue
+		}
 		args = append(args, fmt.Sprintf("--config=%s=%s", k, v))
 	}
-	return args, nil
+	for _, k := range slices.Sorted(maps.Keys(i.GetMultiValues())) {
+		for _, v := range i.GetMultiValues()[k].GetValues() {
+			args = append(args, fmt.Sprintf("--config=%s=%s", k, v))
+		}
+	}
+	return args, secrets, nil
 }
 
-func FirstAutoMatchingIdentity(ctx context.Context, is []*configv2.Identity, info *gitinfo.GitInfo) (*configv2.Identity, error) {
-	logging.Log.Printf("looking for first matching identity")
-	for _, i := range is {
-		matched, err := AutoMatchIdentity(ctx, i, info)
+// ApplyValues sets values in repository config, in order of keys
+func ApplyValues(ctx context.Context, values map[string]string) error {
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		if err := runcmd.SetGitConfigValue(ctx, k, values[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Bindings hold values of named capture groups of regexp conditions
+type Bindings map[string]string
+
+func (b Bindings) merge(other Bindings) Bindings {
+	if len(other) == 0 {
+		return b
+	}
+	if b == nil {
+		b = Bindings{}
+	}
+	for k, v := range other {
+		b[k] = v
+	}
+	return b
+}
+
+type AutoMatch struct {
+	Identity *configv2.Identity
+	Bindings Bindings // values captured by the matching rule
+}
+
+// AllAutoMatchingIdentities returns all matching identities in auto apply order
+func AllAutoMatchingIdentities(ctx context.Context, is []*configv2.Identity, info *gitinfo.GitInfo) ([]*AutoMatch, error) {
+	logging.Log.Printf("looking for all matching identities")
+	matches := []*AutoMatch(nil)
+	now := time.Now()
+	for _, i := range AutoApplyOrder(is) {
+		if !IsActive(i, now) {
+			logging.Log.Printf("matching identity: identity %q skipped, as it is disabled or expired", i.GetIdentifier())
+			continue
+		}
+		matched, bindings, err := AutoMatchIdentity(ctx, i, info)
 		if err != nil {
 			return nil, err
 		}
 		if matched {
-			logging.Log.Printf("first matching identity found %q", i.GetIdentifier())
-			return i, nil
+			matches = append(matches, &AutoMatch{Identity: i, Bindings: bindings})
+		}
+	}
+	logging.Log.Printf("found %d matching identities", len(matches))
+	return matches, nil
+}
+
+// TopPriorityMatches returns leading matches sharing the highest priority, matches are expected in auto apply order
+func TopPriorityMatches(ms []*AutoMatch) []*AutoMatch {
+	for idx, m := range ms {
+		if m.Identity.GetPriority() != ms[0].Identity.GetPriority() {
+			return ms[:idx]
 		}
 	}
-	logging.Log.Printf("no matching identity found")
-	return nil, nil //nolint:nilnil // no matching identity found
+	return ms
 }
 
-func AutoMatchIdentity(ctx context.Context, i *configv2.Identity, info *gitinfo.GitInfo) (bool, error) {
+func AutoMatchIdentity(ctx context.Context, i *configv2.Identity, info *gitinfo.GitInfo) (bool, Bindings, error) {
 	for _, ml := range i.GetAutoApplyWhen() {
-		verdict, err := matchList(ctx, ml, info)
+		verdict, bindings, err := matchList(ctx, ml, info)
 		if err != nil {
-			return false, fmt.Errorf("identity %q: %w", i.GetIdentifier(), err)
+			return false, nil, fmt.Errorf("identity %q: %w", i.GetIdentifier(), err)
 		}
 		if verdict {
 			logging.Log.Printf("matching identity: identity %q matches", i.GetIdentifier())
-			return true, nil
+			return true, bindings, nil
 		}
 	}
 	logging.Log.Printf("matching identity: identity %q do not matches", i.GetIdentifier())
-	return false, nil
+	return false, nil, nil
 }
 
-func matchList(ctx context.Context, ml *configv2.MatchList, info *gitinfo.GitInfo) (bool, error) {
+func matchList(ctx context.Context, ml *configv2.MatchList, info *gitinfo.GitInfo) (bool, Bindings, error) {
 	if len(ml.GetMatch()) == 0 {
-		return false, nil
+		return false, nil, nil
 	}
+	bindings := Bindings(nil)
 	for _, m := range ml.GetMatch() {
-		verdict, err := match(ctx, m, info)
+		verdict, b, err := match(ctx, m, info)
 		if err != nil {
-			return false, err
+			return false, nil, err
 		}
 		if !verdict {
-			return false, nil
+			return false, nil, nil
 		}
+		bindings = bindings.merge(b)
 	}
-	return true, nil
+	return true, bindings, nil
 }
 
-func match(ctx context.Context, m *configv2.Match, info *gitinfo.GitInfo) (verdict bool, err error) {
+func match(ctx context.Context, m *configv2.Match, info *gitinfo.GitInfo) (verdict bool, bindings Bindings, err error) {
 	switch s := m.GetSubject().(type) {
 	case *configv2.Match_Env:
-		verdict, err = matchEnv(ctx, s.Env)
+		verdict, bindings, err = matchEnv(ctx, s.Env)
 	case *configv2.Match_Remote:
-		verdict, err = matchRemote(ctx, s.Remote, info)
+		verdict, bindings, err = matchRemote(ctx, s.Remote, info)
 	case *configv2.Match_Command:
-		verdict, err = matchCommand(ctx, s.Command)
+		verdict, bindings, err = matchCommand(ctx, s.Command)
 	case *configv2.Match_ShellScript:
-		verdict, err = matchShellScript(ctx, s.ShellScript)
+		verdict, bindings, err = matchShellScript(ctx, s.ShellScript)
+	case *configv2.Match_Location:
+		verdict, bindings, err = matchLocation(ctx, s.Location, info)
+	case *configv2.Match_Expression:
+		verdict, bindings, err = matchExpression(ctx, s.Expression, info)
+	case *configv2.Match_Repository:
+		verdict, bindings, err = matchRepository(ctx, s.Repository, info)
 	default:
-		return false, nil // unknown matching subject
+		return false, nil, nil // unknown matching subject
 	}
 	return
 }
 
-func matchEnv(_ context.Context, m *configv2.MatchEnv) (bool, error) {
+func matchEnv(_ context.Context, m *configv2.MatchEnv) (bool, Bindings, error) {
 	envValue, envExists := os.LookupEnv(m.GetName())
 	if !envExists {
-		return m.GetTo().GetNegate(), nil
+		return m.GetTo().GetNegate(), nil, nil
+	}
+	verdict, bindings, err := condition(m.GetTo(), envValue)
+	if err != nil {
+		return false, nil, fmt.Errorf("matching environment variable %q: %w", m.GetName(), err)
+	}
+	return verdict, bindings, nil
+}
+
+func matchRemote(ctx context.Context, m *configv2.MatchRemote, info *gitinfo.GitInfo) (bool, Bindings, error) {
+	for _, r := range info.Remotes {
+		ok, bindings, err := matchSingleRemote(ctx, m, r)
+		if err != nil {
+			return false, nil, err
+		}
+		if ok {
+			return true, bindings, nil
+		}
+	}
+	return false, nil, nil
+}
+
+func matchSingleRemote(_ context.Context, m *configv2.MatchRemote, r *gitinfo.Remote) (bool, Bindings, error) {
+	name, nameBindings, err := condition(m.GetName(), r.Name)
+	if err != nil {
+		return false, nil, fmt.Errorf("matching remote %q name: %w", r.Name, err)
 	}
-	verdict, err := condition(m.GetTo(), envValue)
+	url, urlBindings, err := condition(m.GetUrl(), r.Url)
 	if err != nil {
-		return false, fmt.Errorf("matching environment variable %q: %w", m.GetName(), err)
+		return false, nil, fmt.Errorf("matching remote %q url: %w", r.Name, err)
+	}
+	if !name || !url {
+		return false, nil, nil
 	}
-	return verdict, nil
+	return true, nameBindings.merge(urlBindings), nil
 }
 
-func matchRemote(ctx context.Context, m *configv2.MatchRemote, info *gitinfo.GitInfo) (bool, error) {
+func matchRepository(ctx context.Context, m *configv2.MatchRepository, info *gitinfo.GitInfo) (bool, Bindings, error) {
 	for _, r := range info.Remotes {
-		ok, err := matchSingleRemote(ctx, m, r)
+		ok, bindings, err := matchSingleRepository(ctx, m, r)
 		if err != nil {
-			return false, err
+			return false, nil, err
 		}
 		if ok {
-			return true, nil
+			return true, bindings, nil
 		}
 	}
-	return false, nil
+	return false, nil, nil
 }
 
-func matchSingleRemote(_ context.Context, m *configv2.MatchRemote, r *gitinfo.Remote) (bool, error) {
-	name, err := condition(m.GetName(), r.Name)
+func matchSingleRepository(_ context.Context, m *configv2.MatchRepository, r *gitinfo.Remote) (bool, Bindings, error) {
+	ru, err := gitinfo.ParseRemoteURL(r.Url)
 	if err != nil {
-		return false, fmt.Errorf("matching remote %q name: %w", r.Name, err)
+		logging.Log.Printf("matching identity: url of remote %q cannot be parsed: %v", r.Name, err)
+		return false, nil, nil
+	}
+	parts := []struct {
+		what   string
+		c      *configv2.Condition
+		target string
+	}{
+		{"name", m.GetRemoteName(), r.Name},
+		{"url scheme", m.GetScheme(), ru.Scheme},
+		{"url host", m.GetHost(), ru.Host},
+		{"url port", m.GetPort(), ru.EffectivePort()},
+		{"repository owner", m.GetOwner(), ru.Owner},
+		{"repository name", m.GetName(), ru.Name},
+	}
+	bindings := Bindings(nil)
+	for _, p := range parts {
+		verdict, b, err := condition(p.c, p.target)
+		if err != nil {
+			return false, nil, fmt.Errorf("matching remote %q %s: %w", r.Name, p.what, err)
+		}
+		if !verdict {
+			return false, nil, nil
+		}
+		bindings = bindings.merge(b)
 	}
-	url, err := condition(m.GetUrl(), r.Url)
+	return true, bindings, nil
+}
+
+func matchLocation(_ context.Context, m *configv2.MatchLocation, info *gitinfo.GitInfo) (bool, Bindings, error) {
+	if info.Dir == "" {
+		return m.GetPath().GetNegate(), nil, nil
+	}
+	verdict, bindings, err := condition(m.GetPath(), info.Dir)
 	if err != nil {
-		return false, fmt.Errorf("matching remote %q url: %w", r.Name, err)
+		return false, nil, fmt.Errorf("matching repository location %q: %w", info.Dir, err)
 	}
-	return name && url, nil
+	return verdict, bindings, nil
 }
 
-func matchCommand(ctx context.Context, m *configv2.MatchCommand) (bool, error) {
+func matchCommand(ctx context.Context, m *configv2.MatchCommand) (bool, Bindings, error) {
 	cmd := make([]string, 0, len(m.GetArgs())+1)
 	cmd = append(cmd, m.GetCmd())
 	cmd = append(cmd, m.GetArgs()...)
 	out, err := runcmd.CommandCombinedOutput(ctx, cmd[0], cmd[1:]...)
 	if ee := (&exec.ExitError{}); errors.As(err, &ee) && !m.GetAllowNonZeroExitCode() { // non zero exit code
-		return false, nil
+		return false, nil, nil
 	}
 	if err != nil {
-		return false, runcmd.CommandError(strings.Join(cmd, " "), out, err)
+		return false, nil, runcmd.CommandError(strings.Join(cmd, " "), out, err)
 	}
-	verdict, err := condition(m.GetOutput(), string(out))
+	verdict, bindings, err := condition(m.GetOutput(), string(out))
 	if err != nil {
-		return false, fmt.Errorf("matching command output: %w", err)
+		return false, nil, fmt.Errorf("matching command output: %w", err)
 	}
-	return verdict, nil
+	return verdict, bindings, nil
 }
 
-func matchShellScript(ctx context.Context, m *configv2.MatchShellScript) (bool, error) {
+func matchShellScript(ctx context.Context, m *configv2.MatchShellScript) (bool, Bindings, error) {
 	cmd := append(getAutoMatchShell(), m.GetContent())
 	out, err := runcmd.CommandCombinedOutput(ctx, cmd[0], cmd[1:]...)
 	if ee := (&exec.ExitError{}); errors.As(err, &ee) && !m.GetAllowNonZeroExitCode() { // non zero exit code
-		return false, nil
+		return false, nil, nil
 	}
 	if err != nil {
-		return false, runcmd.CommandError(strings.Join(cmd, " "), out, err)
+		return false, nil, runcmd.CommandError(strings.Join(cmd, " "), out, err)
 	}
-	verdict, err := condition(m.GetOutput(), string(out))
+	verdict, bindings, err := condition(m.GetOutput(), string(out))
 	if err != nil {
-		return false, fmt.Errorf("matching command output: %w", err)
+		return false, nil, fmt.Errorf("matching command output: %w", err)
 	}
-	return verdict, nil
+	return verdict, bindings, nil
 }
 
 func getAutoMatchShell() []string {
@@ -320,7 +653,8 @@ func getAutoMatchShell() []string {
 	return []string{"sh", "-c"}
 }
 
-func condition(c *configv2.Condition, target string) (verdict bool, err error) {
+// condition returns verdict and, for matching regexp conditions, values of named capture groups
+func condition(c *configv2.Condition, target string) (verdict bool, bindings Bindings, err error) {
 	switch c.GetMode() {
 	case configv2.ConditionMode_CONDITION_MODE_UNSPECIFIED:
 		verdict = strings.Contains(target, c.GetValue())
@@ -333,21 +667,27 @@ func condition(c *configv2.Condition, target string) (verdict bool, err error) {
 	case configv2.ConditionMode_CONDITION_MODE_SHELL_PATTERN:
 		v, err := path.Match(c.GetValue(), target)
 		if err != nil {
-			return false, fmt.Errorf("matching shell pattern: %w", err)
+			return false, nil, fmt.Errorf("matching shell pattern: %w", err)
 		}
 		verdict = v
 	case configv2.ConditionMode_CONDITION_MODE_REGEXP:
 		r, err := regexp.Compile(c.GetValue())
 		if err != nil {
-			return false, fmt.Errorf("compiling regexp: %w", err)
+			return false, nil, fmt.Errorf("compiling regexp: %w", err)
+		}
+		submatches := r.FindStringSubmatch(target)
+		verdict = submatches != nil
+		for idx, name := range r.SubexpNames() {
+			if name != "" && verdict && !c.GetNegate() {
+				bindings = bindings.merge(Bindings{name: submatches[idx]})
+			}
 		}
-		verdict = r.MatchString(target)
 	default:
-		return false, errors.New("unknown condition mode")
+		return false, nil, errors.New("unknown condition mode")
 	}
 
 	if c.GetNegate() {
 		verdict = !verdict
 	}
-	return verdict, nil
+	return verdict, bindings, nil
 }
@@ -0,0 +1,385 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/logging"
+)
+
+var confDirExtensions = []string{".json", ".yaml", ".yml"}
+
+type Layer string
+
+const (
+	LayerDirectory Layer = "directory" // directory configuration found in working directory or its parents, nearest file takes precedence
+	LayerUser      Layer = "user"      // user configuration, takes precedence over system configuration
+	LayerSystem    Layer = "system"    // read-only system-wide configuration, loaded only when configuration path is not given explicitly
+)
+
+const directoryConfigName = ".gitidentity.yaml"
+
+type ConfigFile struct {
+	Path   string
+	Format Format
+	Layer  Layer
+	Config *configv2.Config // raw configuration, as stored in the file
+}
+
+type LoadedConfig struct {
+	Files    []*ConfigFile    // loaded files in order of precedence (directory, user and system layer), main configuration file (when present) first within user layer
+	Config   *configv2.Config // effective configuration, merged from all files
+	Profile  string           // active profile, empty when no profile is active
+	Warnings []string         // problems that do not prevent using the configuration
+}
+
+// FileOf returns the file defining identity with the given identifier
+func (lc *LoadedConfig) FileOf(id string) *ConfigFile {
+	for _, f := range lc.Files {
+		for _, i := range identitiesOf(f.Config) {
+			if IdentityAsString(i) == id {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// NearestScopeMatches keeps only matches of identities defined in the highest precedence scope among matches, so that directory configuration nearer to the repository overrides farther one
+func (lc *LoadedConfig) NearestScopeMatches(ms []*AutoMatch) []*AutoMatch {
+	var nearest *ConfigFile
+	nearestIdx := len(lc.Files)
+	for _, m := range ms {
+		if f := lc.FileOf(IdentityAsString(m.Identity)); f != nil {
+			if idx := slices.Index(lc.Files, f); idx < nearestIdx {
+				nearest, nearestIdx = f, idx
+			}
+		}
+	}
+	if nearest == nil {
+		return ms
+	}
+	kept := make([]*AutoMatch, 0, len(ms))
+	for _, m := range ms {
+		if f := lc.FileOf(IdentityAsString(m.Identity)); f != nil && precedenceScope(f) == precedenceScope(nearest) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+func ConfDirPath(path string) (string, error) {
+	if path != "" {
+		return filepath.Join(filepath.Dir(path), "conf.d"), nil
+	}
+	paths := defaultConfigPaths()
+	if len(paths) == 0 {
+		return "", errors.New("unable to determine the location of the configuration directory")
+	}
+	return filepath.Join(filepath.Dir(paths[0]), "conf.d"), nil
+}
+
+func confDirFiles(path string) ([]string, error) {
+	dir, err := ConfDirPath(path)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration directory: %w", err)
+	}
+	files := []string(nil)
+	for _, e := range entries {
+		if e.IsDir() || !hasConfDirExtension(e.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	return files, nil
+}
+
+func hasConfDirExtension(name string) bool {
+	for _, ext := range confDirExtensions {
+		if strings.EqualFold(filepath.Ext(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func directoryConfigFiles() ([]string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	files := []string(nil)
+	for {
+		p := filepath.Join(dir, directoryConfigName)
+		if _, err := os.Stat(p); err == nil {
+			files = append(files, p)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return files, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadConfig loads configuration from all layers, identities and default values of the profile are used when profile is not empty; explicitly given path replaces user configuration file (conf.d next to it is used) and disables system layer, but not directory layer
+func LoadConfig(path, profile string) (*LoadedConfig, error) {
+	dirFiles, err := directoryConfigFiles()
+	if err != nil {
+		return nil, err
+	}
+	confD, err := confDirFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &configLoader{visited: map[string]bool{}}
+	mainPath, err := ConfigFilePath(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && (len(dirFiles) > 0 || len(confD) > 0 || path == "" && systemConfigExists()):
+		logging.Log.Printf("config: no main configuration file")
+	case err != nil:
+		return nil, fmt.Errorf("reading configuration file: %w", err)
+	default:
+		if err := l.load(mainPath, LayerUser); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range confD {
+		if err := l.load(p, LayerUser); err != nil {
+			return nil, fmt.Errorf("loading %q: %w", p, err)
+		}
+	}
+	if path == "" {
+		if systemPath, err := SystemConfigFilePath(); err == nil {
+			if err := l.load(systemPath, LayerSystem); err != nil {
+				return nil, fmt.Errorf("loading system configuration %q: %w", systemPath, err)
+			}
+		}
+	}
+
+	warnings := []string(nil)
+	trusted := trustedDirectories(l.files) // directory layer is loaded last, as trust is decided by user and system configuration
+	dl := &configLoader{visited: l.visited}
+	for _, p := range dirFiles {
+		if !isTrusted(p, trusted) && insideWorktree(p) {
+			warnings = append(warnings, fmt.Sprintf("ignoring directory configuration %q inside repository, add its directory to trusted_directories of user configuration to use it", p))
+			continue
+		}
+		if err := dl.load(p, LayerDirectory); err != nil {
+			return nil, fmt.Errorf("loading %q: %w", p, err)
+		}
+	}
+	for _, f := range dl.files {
+		if isTrusted(f.Path, trusted) {
+			continue
+		}
+		if err := checkUntrustedConfig(f.Config); err != nil {
+			return nil, fmt.Errorf("untrusted directory configuration %q: %w", f.Path, err)
+		}
+	}
+	l.files = append(dl.files, l.files...)
+
+	for _, f := range l.files {
+		logging.Log.Printf("config: using %s layer file %q", f.Layer, f.Path)
+		if w := orderWarning(f); w != "" {
+			warnings = append(warnings, w)
+		}
+	}
+	warnings = append(warnings, unusedRulesWarnings(l.files)...)
+
+	rules, err := sharedRules(l.files)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProfiles(l.files, rules); err != nil {
+		return nil, err
+	}
+	lc := &LoadedConfig{Files: l.files, Profile: profile}
+	if profile != "" && !slices.Contains(lc.Profiles(), profile) {
+		return nil, fmt.Errorf("profile %q is not defined", profile)
+	}
+
+	merged, err := mergeConfigFiles(profiledFiles(l.files, rules, profile))
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ResolveConfig(merged)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range cfg.GetList() {
+		i.Profile = profile
+	}
+	lc.Config = cfg
+	lc.Warnings = append(warnings, expiryWarnings(cfg.GetList(), time.Now())...)
+	return lc, nil
+}
+
+// orderWarning reports files of versions that used to sort identities alphabetically, when auto apply order of their identities has changed
+func orderWarning(f *ConfigFile) string {
+	if v := f.Config.GetVersion(); v != "v1" && v != "v2" {
+		return ""
+	}
+	auto := []*configv2.Identity(nil)
+	for _, i := range f.Config.GetList() {
+		if len(i.GetAutoApplyWhen()) > 0 {
+			auto = append(auto, i)
+		}
+	}
+	sorted := slices.Clone(auto)
+	SortIdentities(sorted)
+	if slices.Equal(IdentitiesAsStrings(AutoApplyOrder(auto)), IdentitiesAsStrings(sorted)) {
+		return ""
+	}
+	return fmt.Sprintf("configuration %q: identities are now auto applied in configuration order (and by priority) instead of alphabetical order, review the order and set version to v3 to acknowledge", f.Path)
+}
+
+type configLoader struct {
+	files   []*ConfigFile
+	visited map[string]bool
+}
+
+func systemConfigExists() bool {
+	_, err := SystemConfigFilePath()
+	return err == nil
+}
+
+func (l *configLoader) load(path string, layer Layer) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if l.visited[abs] {
+		logging.Log.Printf("config %q already loaded", path)
+		return nil
+	}
+	l.visited[abs] = true
+
+	cfg, format, err := ReadConfig(path)
+	if err != nil {
+		return err
+	}
+	l.files = append(l.files, &ConfigFile{Path: path, Format: format, Layer: layer, Config: cfg})
+	for _, pattern := range cfg.GetInclude() {
+		paths, err := includePaths(path, pattern)
+		if err != nil {
+			return fmt.Errorf("including %q: %w", pattern, err)
+		}
+		for _, p := range paths {
+			if err := l.load(p, layer); err != nil {
+				return fmt.Errorf("including %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+func includePaths(from, pattern string) ([]string, error) {
+	if home, err := os.UserHomeDir(); err == nil {
+		pattern = expandHome(pattern, home)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	logging.Log.Printf("config include pattern %q matched %d files", pattern, len(paths))
+	return paths, nil
+}
+
+// precedenceScope returns scope in which duplicate definitions are reported as errors, definitions from different scopes are merged
+func precedenceScope(f *ConfigFile) string {
+	if f.Layer == LayerDirectory {
+		return f.Path
+	}
+	return string(f.Layer)
+}
+
+// mergeConfigFiles merges identities and default values of all files, files are expected in order of precedence
+func mergeConfigFiles(files []*ConfigFile) (*configv2.Config, error) {
+	merged := EmptyConfig()
+	if len(files) > 0 {
+		merged.Version = files[0].Config.GetVersion()
+	}
+	identityOrigin := map[string]*ConfigFile{}
+	identityIndex := map[string]int{}
+	defaultOrigin := map[string]*ConfigFile{}
+	for _, f := range files {
+		for _, i := range f.Config.GetList() {
+			id := IdentityAsString(i)
+			origin, ok := identityOrigin[id]
+			switch {
+			case ok && precedenceScope(origin) != precedenceScope(f):
+				logging.Log.Printf("config: %s identity %q from %q merged beneath %s identity from %q", f.Layer, id, f.Path, origin.Layer, origin.Path)
+				merged.List[identityIndex[id]] = mergeIdentityBeneath(merged.List[identityIndex[id]], i)
+				continue
+			case ok && origin.Path != f.Path:
+				return nil, fmt.Errorf("identity %q is defined in both %q and %q", id, origin.Path, f.Path)
+			case ok:
+				return nil, fmt.Errorf("identity %q is defined more than once in %q", id, f.Path)
+			case !ok:
+				identityOrigin[id] = f
+				identityIndex[id] = len(merged.List)
+			}
+			merged.List = append(merged.List, i)
+		}
+		merged.FirstMatchWins = merged.GetFirstMatchWins() || f.Config.GetFirstMatchWins()
+		for k, v := range f.Config.GetDefaults() {
+			if origin, ok := defaultOrigin[k]; ok && precedenceScope(origin) != precedenceScope(f) {
+				continue
+			} else if ok && merged.GetDefaults()[k] != v {
+				return nil, fmt.Errorf("default value of %q is defined differently in %q and %q", k, origin.Path, f.Path)
+			}
+			if merged.Defaults == nil {
+				merged.Defaults = map[string]string{}
+			}
+			defaultOrigin[k] = f
+			merged.Defaults[k] = v
+		}
+	}
+	return merged, nil
+}
+
+// mergeIdentityBeneath returns copy of dst with fields not set in dst taken from src, for maps missing keys are taken from src
+func mergeIdentityBeneath(dst, src *configv2.Identity) *configv2.Identity {
+	merged := proto.CloneOf(dst)
+	m := merged.ProtoReflect()
+	proto.CloneOf(src).ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			dstMap := m.Mutable(fd).Map()
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				if !dstMap.Has(k) {
+					dstMap.Set(k, mv)
+				}
+				return true
+			})
+		case !m.Has(fd):
+			m.Set(fd, v)
+		}
+		return true
+	})
+	return merged
+}
@@ -23,6 +23,7 @@ type unmarshaller func([]byte) (*configv2.Config, error)
 var versionStringToUnmarshaller = map[string]unmarshaller{
 	"v1": unmarshalConfigV1,
 	"v2": unmarshalConfigV2,
+	"v3": unmarshalConfigV3,
 }
 
 type VersionEntity interface {
@@ -73,20 +74,89 @@ func UnmarshalAndValidateConfig(cfgBytes []byte) (*configv2.Config, Format, erro
 	if err != nil {
 		return nil, format, fmt.Errorf("unmarshalling configuration: %w", err)
 	}
-	SortIdentities(cfg.GetList())
 	return cfg, format, nil
 }
 
-func defaultConfigPaths() []string {
+func userConfigDir() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
 	p, err := os.UserHomeDir()
 	if err != nil {
-		return nil
+		return ""
 	}
+	return filepath.Join(p, ".config")
+}
+
+func systemConfigDirs() []string {
+	dirs := []string(nil)
+	for _, dir := range filepath.SplitList(os.Getenv("XDG_CONFIG_DIRS")) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		dirs = append(dirs, "/etc/xdg")
+	}
+	return append(dirs, "/etc")
+}
+
+func configPathsIn(dir string) []string {
 	return []string{
-		filepath.Join(p, ".config", "gitidentity", "config.json"),
-		filepath.Join(p, ".config", "gitidentity", "config.yaml"),
-		filepath.Join(p, ".config", "gitidentity", "config.yml"),
+		filepath.Join(dir, "gitidentity", "config.json"),
+		filepath.Join(dir, "gitidentity", "config.yaml"),
+		filepath.Join(dir, "gitidentity", "config.yml"),
+	}
+}
+
+func defaultConfigPaths() []string {
+	dir := userConfigDir()
+	if dir == "" {
+		return nil
+	}
+	return configPathsIn(dir)
+}
+
+func systemConfigPaths() []string {
+	paths := []string(nil)
+	for _, dir := range systemConfigDirs() {
+		paths = append(paths, configPathsIn(dir)...)
+	}
+	return paths
+}
+
+func ConfigFilePath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return firstExistingPath(defaultConfigPaths())
+}
+
+func SystemConfigFilePath() (string, error) {
+	return firstExistingPath(systemConfigPaths())
+}
+
+func firstExistingPath(paths []string) (string, error) {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+func NewConfigFilePath(path string) (string, Format, error) {
+	if path == "" {
+		paths := defaultConfigPaths()
+		if len(paths) == 0 {
+			return "", FormatUnknown, errors.New("unable to determine the location of the configuration file")
+		}
+		path = paths[1] // prefer YAML for new files
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return path, FormatJSON, nil
 	}
+	return path, FormatYAML, nil
 }
 
 func readConfigBytes(path string) ([]byte, error) {
@@ -130,7 +200,7 @@ func ReadConfig(path string) (*configv2.Config, Format, error) {
 }
 
 func EmptyConfig() *configv2.Config {
-	return &configv2.Config{Version: "v2"}
+	return &configv2.Config{Version: "v3"}
 }
 
 func osSafeFileWrite(name string, data []byte, perm os.FileMode) error {
@@ -0,0 +1,164 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/logging"
+)
+
+var profileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validateProfiles checks profiles of all loaded files, as identities of a profile may extend identities from any file, profiles are checked once all files are loaded
+func validateProfiles(files []*ConfigFile, rules map[string]*configv2.MatchList) error {
+	names := []string(nil)
+	for _, f := range files {
+		if err := validateProfileFields(f.Config); err != nil {
+			return fmt.Errorf("configuration %q: %w", f.Path, err)
+		}
+		for name := range f.Config.GetProfiles() {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		merged, err := mergeConfigFiles(profiledFiles(files, rules, name))
+		if err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		if _, err := resolveInheritance(merged.GetList()); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateProfileFields(cfg *configv2.Config) error {
+	for _, i := range cfg.GetList() {
+		if i.GetProfile() != "" {
+			return fmt.Errorf("identity %q: profile is recorded automatically and cannot be set in configuration", IdentityAsString(i))
+		}
+	}
+	for name, p := range cfg.GetProfiles() {
+		if !profileNameRegexp.MatchString(name) {
+			return fmt.Errorf("profile %q: name must start with letter or digit and contain only letters, digits, dots, underscores or dashes", name)
+		}
+		for _, i := range p.GetList() {
+			if i.GetProfile() != "" {
+				return fmt.Errorf("profile %q: identity %q: profile is recorded automatically and cannot be set in configuration", name, IdentityAsString(i))
+			}
+			if slices.ContainsFunc(cfg.GetList(), func(o *configv2.Identity) bool { return IdentityAsString(o) == IdentityAsString(i) }) {
+				return fmt.Errorf("profile %q: identity %q is already defined outside of the profile", name, IdentityAsString(i))
+			}
+		}
+	}
+	return nil
+}
+
+// profiledFiles returns copies of files with shared rules resolved and identities and default values of the profile merged in
+func profiledFiles(files []*ConfigFile, rules map[string]*configv2.MatchList, profile string) []*ConfigFile {
+	profiled := make([]*ConfigFile, len(files))
+	for idx, f := range files {
+		profiled[idx] = &ConfigFile{Path: f.Path, Format: f.Format, Layer: f.Layer, Config: withProfile(withRules(f.Config, rules), profile)}
+	}
+	return profiled
+}
+
+// withProfile returns configuration with identities and default values of the profile merged in, configuration is returned as is when it does not define the profile
+func withProfile(cfg *configv2.Config, profile string) *configv2.Config {
+	p, ok := cfg.GetProfiles()[profile]
+	if !ok {
+		return cfg
+	}
+	merged := proto.CloneOf(cfg)
+	merged.Profiles = nil
+	for _, i := range p.GetList() {
+		merged.List = append(merged.List, proto.CloneOf(i))
+	}
+	for k, v := range p.GetDefaults() {
+		if merged.Defaults == nil {
+			merged.Defaults = map[string]string{}
+		}
+		merged.Defaults[k] = v
+	}
+	return merged
+}
+
+// identitiesOf returns identities defined in configuration, including identities of all profiles
+func identitiesOf(cfg *configv2.Config) []*configv2.Identity {
+	is := slices.Clone(cfg.GetList())
+	for _, name := range slices.Sorted(maps.Keys(cfg.GetProfiles())) {
+		is = append(is, cfg.GetProfiles()[name].GetList()...)
+	}
+	return is
+}
+
+// Profiles returns sorted names of profiles defined in all loaded files
+func (lc *LoadedConfig) Profiles() []string {
+	names := []string(nil)
+	for _, f := range lc.Files {
+		for name := range f.Config.GetProfiles() {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ProfileFilePath returns path of file storing name of the default profile, it is kept next to the main configuration file
+func ProfileFilePath(path string) (string, error) {
+	if path != "" {
+		return filepath.Join(filepath.Dir(path), "profile"), nil
+	}
+	paths := defaultConfigPaths()
+	if len(paths) == 0 {
+		return "", errors.New("unable to determine the location of the configuration directory")
+	}
+	return filepath.Join(filepath.Dir(paths[0]), "profile"), nil
+}
+
+// DefaultProfile returns name of the persisted default profile, or empty string when none is set
+func DefaultProfile(path string) (string, error) {
+	p, err := ProfileFilePath(path)
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading default profile: %w", err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// SetDefaultProfile persists name of the default profile, empty name removes the default
+func SetDefaultProfile(path, profile string) error {
+	p, err := ProfileFilePath(path)
+	if err != nil {
+		return err
+	}
+	if profile == "" {
+		logging.Log.Printf("removing default profile file %q", p)
+		return removeIfExists(p)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	logging.Log.Printf("writing default profile %q to %q", profile, p)
+	return osSafeFileWrite(p, []byte(profile+"\n"), 0o600)
+}
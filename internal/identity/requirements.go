@@ -0,0 +1,124 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"buf.build/go/protoyaml"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+	"github.com/daishe/gitidentity/internal/logging"
+)
+
+const RequirementsFileName = ".gitidentity-requirements.yaml"
+
+// ReadRequirements reads requirements declared in the repository top-level directory, it returns nil when repository declares no requirements
+func ReadRequirements(dir string) (*configv2.Requirements, error) {
+	if dir == "" {
+		return nil, nil //nolint:nilnil // not in repository
+	}
+	path := filepath.Join(dir, RequirementsFileName)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil //nolint:nilnil // no requirements declared
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading repository requirements: %w", err)
+	}
+	req := &configv2.Requirements{}
+	if err := protoyaml.Unmarshal(b, req); err != nil {
+		return nil, fmt.Errorf("unmarshalling repository requirements %q: %w", path, err)
+	}
+	for _, r := range req.GetValues() {
+		if err := ValidateGitConfigKey(r.GetKey()); err != nil {
+			return nil, fmt.Errorf("repository requirements %q: %w", path, err)
+		}
+		if err := ValidateCondition(r.GetValue()); err != nil {
+			return nil, fmt.Errorf("repository requirements %q: key %q: %w", path, r.GetKey(), err)
+		}
+	}
+	logging.Log.Printf("repository requirements %q read, #%d number of requirements", path, len(req.GetValues()))
+	return req, nil
+}
+
+// RequirementViolations returns explanations of all requirements that the identity does not satisfy and of requirements that cannot be checked before the identity is applied; values are checked as they would be applied, with templates expanded and captured values substituted
+func RequirementViolations(i *configv2.Identity, info *gitinfo.GitInfo, bindings Bindings, autoSelected bool, req *configv2.Requirements) ([]string, []string, error) {
+	if len(req.GetValues()) == 0 {
+		return nil, nil, nil
+	}
+	values, expandErr := expandedValues(i, info, bindings, autoSelected)
+	violations, unchecked := []string(nil), []string(nil)
+	for _, r := range req.GetValues() {
+		if _, ok := i.GetValuesFrom()[r.GetKey()]; ok {
+			unchecked = append(unchecked, fmt.Sprintf("%s is resolved from command only when identity is applied", r.GetKey()))
+			continue
+		}
+		if expandErr != nil {
+			unchecked = append(unchecked, fmt.Sprintf("%s cannot be resolved: %v", r.GetKey(), expandErr))
+			continue
+		}
+		verdict, _, err := condition(r.GetValue(), values[r.GetKey()])
+		if err != nil {
+			return nil, nil, fmt.Errorf("requirement on %q: %w", r.GetKey(), err)
+		}
+		if verdict {
+			continue
+		}
+		v := fmt.Sprintf("%s must %s, got %q", r.GetKey(), describeCondition(r.GetValue()), values[r.GetKey()])
+		if r.GetReason() != "" {
+			v += " (" + r.GetReason() + ")"
+		}
+		violations = append(violations, v)
+	}
+	return violations, unchecked, nil
+}
+
+// CompliantIdentities splits identities into ones satisfying requirements and explanations for the rest, identities with requirements that cannot be checked before applying are considered compliant
+func CompliantIdentities(is []*configv2.Identity, info *gitinfo.GitInfo, req *configv2.Requirements) ([]*configv2.Identity, map[string][]string, error) {
+	compliant := make([]*configv2.Identity, 0, len(is))
+	violations := map[string][]string{}
+	for _, i := range is {
+		v, unchecked, err := RequirementViolations(i, info, nil, false, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(unchecked) > 0 {
+			logging.Log.Printf("identity %q cannot be checked against repository requirements: %s", IdentityAsString(i), strings.Join(unchecked, "; "))
+		}
+		if len(v) > 0 {
+			logging.Log.Printf("identity %q does not satisfy repository requirements: %s", IdentityAsString(i), strings.Join(v, "; "))
+			violations[IdentityAsString(i)] = v
+			continue
+		}
+		compliant = append(compliant, i)
+	}
+	return compliant, violations, nil
+}
+
+func describeCondition(c *configv2.Condition) string {
+	d := ""
+	switch c.GetMode() {
+	case configv2.ConditionMode_CONDITION_MODE_UNSPECIFIED:
+		d = "contain %q"
+	case configv2.ConditionMode_CONDITION_MODE_PREFIX:
+		d = "start with %q"
+	case configv2.ConditionMode_CONDITION_MODE_SUFFIX:
+		d = "end with %q"
+	case configv2.ConditionMode_CONDITION_MODE_FULL:
+		d = "equal %q"
+	case configv2.ConditionMode_CONDITION_MODE_SHELL_PATTERN:
+		d = "match shell pattern %q"
+	case configv2.ConditionMode_CONDITION_MODE_REGEXP:
+		d = "match regexp %q"
+	default:
+		d = "satisfy condition %q"
+	}
+	if c.GetNegate() {
+		d = "not " + d
+	}
+	return fmt.Sprintf(d, c.GetValue())
+}
@@ -0,0 +1,196 @@
+package identity
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+)
+
+func ResolveConfig(cfg *configv2.Config) (*configv2.Config, error) {
+	resolved := proto.CloneOf(cfg)
+	list, err := resolveInheritance(resolved.GetList())
+	if err != nil {
+		return nil, err
+	}
+	resolved.List = applyDefaults(list, resolved.GetDefaults())
+	for _, i := range resolved.GetList() {
+		if err := validateUnsetValues(i); err != nil {
+			return nil, err
+		}
+		if err := validateMultiValues(i); err != nil {
+			return nil, err
+		}
+		if _, _, err := expiresAt(i); err != nil {
+			return nil, err
+		}
+		if err := validateExpressions(i); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// validateMultiValues checks that multi-valued keys are valid and are not set as single values at the same time
+func validateMultiValues(i *configv2.Identity) error {
+	for k := range i.GetMultiValues() {
+		if err := ValidateGitConfigKey(k); err != nil {
+			return fmt.Errorf("identity %q: multi values: %w", IdentityAsString(i), err)
+		}
+		_, inValues := i.GetValues()[k]
+		_, inValuesFrom := i.GetValuesFrom()[k]
+		if inValues || inValuesFrom {
+			return fmt.Errorf("identity %q: key %q is both single and multi-valued", IdentityAsString(i), k)
+		}
+	}
+	return nil
+}
+
+// validateUnsetValues checks that keys to unset are valid and are not set by identity at the same time
+func validateUnsetValues(i *configv2.Identity) error {
+	for _, k := range i.GetUnsetValues() {
+		if err := ValidateGitConfigKey(k); err != nil {
+			return fmt.Errorf("identity %q: unset values: %w", IdentityAsString(i), err)
+		}
+		_, inValues := i.GetValues()[k]
+		_, inValuesFrom := i.GetValuesFrom()[k]
+		_, inMultiValues := i.GetMultiValues()[k]
+		if inValues || inValuesFrom || inMultiValues {
+			return fmt.Errorf("identity %q: key %q is both set and unset", IdentityAsString(i), k)
+		}
+	}
+	return nil
+}
+
+// applyDefaults merges config-wide default values beneath values of every identity, empty identity value disables a default
+func applyDefaults(is []*configv2.Identity, defaults map[string]string) []*configv2.Identity {
+	if len(defaults) == 0 {
+		return is
+	}
+	for _, i := range is {
+		values := make(map[string]string, len(defaults)+len(i.GetValues()))
+		for k, v := range defaults {
+			_, fromCommand := i.GetValuesFrom()[k] // value resolved from command takes precedence
+			_, multiValued := i.GetMultiValues()[k]
+			if !fromCommand && !multiValued && !slices.Contains(i.GetUnsetValues(), k) {
+				values[k] = v
+			}
+		}
+		for k, v := range i.GetValues() {
+			if _, isDefault := defaults[k]; isDefault && v == "" {
+				delete(values, k)
+				continue
+			}
+			values[k] = v
+		}
+		i.Values = values
+	}
+	return is
+}
+
+// resolveInheritance merges base identities into identities extending them and drops abstract identities
+func resolveInheritance(is []*configv2.Identity) ([]*configv2.Identity, error) {
+	r := &inheritanceResolver{
+		byID:     make(map[string][]*configv2.Identity, len(is)),
+		resolved: make(map[*configv2.Identity]*configv2.Identity, len(is)),
+	}
+	for _, i := range is {
+		id := IdentityAsString(i)
+		r.byID[id] = append(r.byID[id], i)
+	}
+
+	list := make([]*configv2.Identity, 0, len(is))
+	for _, i := range is {
+		resolved, err := r.resolve(i, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !resolved.GetAbstract() {
+			list = append(list, resolved)
+		}
+	}
+	return list, nil
+}
+
+type inheritanceResolver struct {
+	byID     map[string][]*configv2.Identity
+	resolved map[*configv2.Identity]*configv2.Identity
+}
+
+func (r *inheritanceResolver) resolve(i *configv2.Identity, chain []string) (*configv2.Identity, error) {
+	if resolved := r.resolved[i]; resolved != nil {
+		return resolved, nil
+	}
+	id := IdentityAsString(i)
+	for idx, c := range chain {
+		if c == id {
+			return nil, fmt.Errorf("identity %q: inheritance cycle %s", id, strings.Join(append(chain[idx:], id), " -> "))
+		}
+	}
+	chain = append(chain, id)
+
+	merged := &configv2.Identity{Values: map[string]string{}}
+	for _, baseID := range i.GetExtends() {
+		candidates := r.byID[baseID]
+		switch {
+		case len(candidates) == 0:
+			return nil, fmt.Errorf("identity %q: extended identity %q does not exist", id, baseID)
+		case len(candidates) > 1:
+			return nil, fmt.Errorf("identity %q: extended identity %q is defined more than once", id, baseID)
+		}
+		base, err := r.resolve(candidates[0], chain)
+		if err != nil {
+			return nil, err
+		}
+		mergeIdentity(merged, base)
+	}
+	mergeIdentity(merged, i)
+
+	resolved := proto.CloneOf(i)
+	resolved.Extends = nil
+	resolved.Values = merged.GetValues()
+	resolved.AutoApplyWhen = merged.GetAutoApplyWhen()
+	resolved.ValuesFrom = merged.GetValuesFrom()
+	resolved.UnsetValues = merged.GetUnsetValues()
+	resolved.MultiValues = merged.GetMultiValues()
+	if len(resolved.GetValues()) == 0 {
+		resolved.Values = nil
+	}
+	r.resolved[i] = resolved
+	return resolved, nil
+}
+
+// mergeIdentity merges values and rules of src into dst, values of src take precedence
+func mergeIdentity(dst, src *configv2.Identity) {
+	for k, v := range src.GetValues() {
+		dst.Values[k] = v
+	}
+	for k, v := range src.GetValuesFrom() {
+		if dst.ValuesFrom == nil {
+			dst.ValuesFrom = map[string]*configv2.ValueSource{}
+		}
+		dst.ValuesFrom[k] = proto.CloneOf(v)
+	}
+	for k, v := range src.GetMultiValues() {
+		if dst.MultiValues == nil {
+			dst.MultiValues = map[string]*configv2.ValueList{}
+		}
+		dst.MultiValues[k] = proto.CloneOf(v)
+	}
+	dst.UnsetValues = appendMissing(dst.GetUnsetValues(), src.GetUnsetValues())
+	for _, ml := range src.GetAutoApplyWhen() {
+		dst.AutoApplyWhen = append(dst.AutoApplyWhen, proto.CloneOf(ml))
+	}
+}
+
+func appendMissing(dst, src []string) []string {
+	for _, v := range src {
+		if !slices.Contains(dst, v) {
+			dst = append(dst, v)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,108 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+)
+
+// validateRuleDefinitions checks that shared rules defined in configuration do not reference other rules and have match rules
+func validateRuleDefinitions(cfg *configv2.Config) error {
+	for name, ml := range cfg.GetRules() {
+		if name == "" {
+			return errors.New("shared rule name cannot be empty")
+		}
+		if len(ml.GetRules()) != 0 {
+			return fmt.Errorf("shared rule %q: shared rules cannot reference other shared rules", name)
+		}
+		if len(ml.GetMatch()) == 0 {
+			return fmt.Errorf("shared rule %q: no match rules", name)
+		}
+	}
+	return nil
+}
+
+// sharedRules returns shared rules of all files, files are expected in order of precedence; rule defined in nearer scope takes precedence, while differing definitions within the same scope are an error; all references to shared rules in any file must be resolvable
+func sharedRules(files []*ConfigFile) (map[string]*configv2.MatchList, error) {
+	rules := map[string]*configv2.MatchList{}
+	ruleOrigin := map[string]*ConfigFile{}
+	for _, f := range files {
+		for _, name := range slices.Sorted(maps.Keys(f.Config.GetRules())) {
+			ml := f.Config.GetRules()[name]
+			if origin, ok := ruleOrigin[name]; ok && precedenceScope(origin) != precedenceScope(f) {
+				continue
+			} else if ok && !proto.Equal(rules[name], ml) {
+				return nil, fmt.Errorf("shared rule %q is defined differently in %q and %q", name, origin.Path, f.Path)
+			}
+			ruleOrigin[name] = f
+			rules[name] = ml
+		}
+	}
+	for _, f := range files {
+		for _, i := range identitiesOf(f.Config) {
+			for _, ml := range i.GetAutoApplyWhen() {
+				for _, name := range ml.GetRules() {
+					if _, ok := rules[name]; !ok {
+						return nil, fmt.Errorf("configuration %q: identity %q: shared rule %q does not exist", f.Path, IdentityAsString(i), name)
+					}
+				}
+			}
+		}
+	}
+	return rules, nil
+}
+
+// withRules returns configuration with references to shared rules replaced by match rules of referenced rules
+func withRules(cfg *configv2.Config, rules map[string]*configv2.MatchList) *configv2.Config {
+	if len(rules) == 0 {
+		return cfg
+	}
+	resolved := proto.CloneOf(cfg)
+	for _, i := range identitiesOf(resolved) {
+		for _, ml := range i.GetAutoApplyWhen() {
+			matches := []*configv2.Match(nil)
+			for _, name := range ml.GetRules() {
+				for _, m := range rules[name].GetMatch() {
+					matches = append(matches, proto.CloneOf(m))
+				}
+			}
+			ml.Match = append(matches, ml.GetMatch()...)
+			ml.Rules = nil
+		}
+	}
+	resolved.Rules = nil
+	return resolved
+}
+
+// unusedRulesWarnings reports shared rules of each file that are not referenced by any identity of any file
+func unusedRulesWarnings(files []*ConfigFile) []string {
+	used := map[string]bool{}
+	for _, f := range files {
+		for _, i := range identitiesOf(f.Config) {
+			for _, ml := range i.GetAutoApplyWhen() {
+				for _, name := range ml.GetRules() {
+					used[name] = true
+				}
+			}
+		}
+	}
+	warnings := []string(nil)
+	for _, f := range files {
+		unused := []string(nil)
+		for _, name := range slices.Sorted(maps.Keys(f.Config.GetRules())) {
+			if !used[name] {
+				unused = append(unused, name)
+			}
+		}
+		if len(unused) > 0 {
+			warnings = append(warnings, fmt.Sprintf("configuration %q: shared rules not referenced by any identity: %s", f.Path, strings.Join(unused, ", ")))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,166 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+)
+
+// executableKeyPatterns match git config keys which values git runs as commands (or which make git load other configuration)
+var executableKeyPatterns = []string{
+	"alias.*",
+	"core.askPass",
+	"core.editor",
+	"core.fsmonitor",
+	"core.gitProxy",
+	"core.hooksPath",
+	"core.pager",
+	"core.sshCommand",
+	"diff.*.command",
+	"diff.*.textconv",
+	"diff.external",
+	"filter.*",
+	"gpg.*program",
+	"include.path",
+	"includeIf.*",
+	"merge.*.driver",
+	"pager.*",
+	"remote.*.receivepack",
+	"remote.*.uploadpack",
+	"sequence.editor",
+	"uploadpack.packObjectsHook",
+	"*.helper",
+}
+
+// IsExecutableKey reports if git runs value of the key as a command
+func IsExecutableKey(key string) bool {
+	for _, p := range executableKeyPatterns {
+		if keyPatternRegexp(p).MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func realPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		p = resolved
+	}
+	return filepath.Clean(p)
+}
+
+func isWithin(p, dir string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// trustedDirectories returns directories trusted in user and system configuration files
+func trustedDirectories(files []*ConfigFile) []string {
+	home, _ := os.UserHomeDir()
+	dirs := []string(nil)
+	for _, f := range files {
+		if f.Layer == LayerDirectory {
+			continue // directory configuration cannot trust itself
+		}
+		for _, d := range f.Config.GetTrustedDirectories() {
+			if home != "" {
+				d = expandHome(d, home)
+			}
+			if !filepath.IsAbs(d) {
+				d = filepath.Join(filepath.Dir(f.Path), d)
+			}
+			dirs = append(dirs, realPath(d))
+		}
+	}
+	return dirs
+}
+
+func isTrusted(path string, trusted []string) bool {
+	p := realPath(filepath.Dir(path))
+	for _, d := range trusted {
+		if isWithin(p, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// insideWorktree reports if path is inside the worktree of repository containing the working directory, such files come with the repository and cannot be trusted implicitly
+func insideWorktree(path string) bool {
+	wd, err := os.Getwd()
+	if err != nil {
+		return false
+	}
+	worktree, err := gitinfo.WorktreeDir(wd)
+	if err != nil {
+		return false
+	}
+	return isWithin(realPath(path), realPath(worktree))
+}
+
+// checkUntrustedConfig rejects configuration which could make gitidentity or git run commands, untrusted directory configuration is limited to declarative rules and values
+func checkUntrustedConfig(cfg *configv2.Config) error {
+	for k := range cfg.GetDefaults() {
+		if IsExecutableKey(k) {
+			return fmt.Errorf("default value of %q: executable git config keys are only allowed in trusted directories", k)
+		}
+	}
+	for name, p := range cfg.GetProfiles() {
+		for k := range p.GetDefaults() {
+			if IsExecutableKey(k) {
+				return fmt.Errorf("profile %q: default value of %q: executable git config keys are only allowed in trusted directories", name, k)
+			}
+		}
+	}
+	for name, ml := range cfg.GetRules() {
+		if err := checkUntrustedMatchList(ml); err != nil {
+			return fmt.Errorf("shared rule %q: %w", name, err)
+		}
+	}
+	for _, i := range identitiesOf(cfg) {
+		if err := checkUntrustedIdentity(i); err != nil {
+			return fmt.Errorf("identity %q: %w", IdentityAsString(i), err)
+		}
+	}
+	return nil
+}
+
+func checkUntrustedIdentity(i *configv2.Identity) error {
+	if len(i.GetValuesFrom()) > 0 {
+		return errors.New("values resolved from commands are only allowed in trusted directories")
+	}
+	for k := range i.GetValues() {
+		if IsExecutableKey(k) {
+			return fmt.Errorf("value of %q: executable git config keys are only allowed in trusted directories", k)
+		}
+	}
+	for k := range i.GetMultiValues() {
+		if IsExecutableKey(k) {
+			return fmt.Errorf("value of %q: executable git config keys are only allowed in trusted directories", k)
+		}
+	}
+	for _, ml := range i.GetAutoApplyWhen() {
+		if err := checkUntrustedMatchList(ml); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkUntrustedMatchList(ml *configv2.MatchList) error {
+	for _, m := range ml.GetMatch() {
+		switch m.GetSubject().(type) {
+		case *configv2.Match_Command, *configv2.Match_ShellScript:
+			return errors.New("command and shell script match rules are only allowed in trusted directories")
+		}
+	}
+	return nil
+}
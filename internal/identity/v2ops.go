@@ -1,6 +1,9 @@
 package identity
 
 import (
+	"errors"
+	"fmt"
+
 	"buf.build/go/protoyaml"
 
 	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
@@ -11,5 +14,21 @@ func unmarshalConfigV2(cfgBytes []byte) (*configv2.Config, error) {
 	if err := (protoyaml.UnmarshalOptions{AllowPartial: false, DiscardUnknown: false}).Unmarshal(cfgBytes, cfg); err != nil {
 		return nil, err
 	}
+	if len(cfg.GetProfiles()) != 0 {
+		return nil, errors.New("profiles require configuration version v3")
+	}
+	if len(cfg.GetRules()) != 0 {
+		return nil, errors.New("shared rules require configuration version v3")
+	}
+	for _, i := range cfg.GetList() {
+		if len(i.GetExtends()) != 0 || i.GetAbstract() {
+			return nil, fmt.Errorf("identity %q: identity inheritance requires configuration version v3", IdentityAsString(i))
+		}
+		for _, ml := range i.GetAutoApplyWhen() {
+			if len(ml.GetRules()) != 0 {
+				return nil, fmt.Errorf("identity %q: shared rules require configuration version v3", IdentityAsString(i))
+			}
+		}
+	}
 	return cfg, nil
 }
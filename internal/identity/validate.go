@@ -0,0 +1,49 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"path"
+	"regexp"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+)
+
+var gitConfigKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9-]+(\..+)?\.[A-Za-z][A-Za-z0-9-]*$`)
+
+func ValidateGitConfigKey(key string) error {
+	if !gitConfigKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid git config key %q, expected section.name or section.subsection.name", key)
+	}
+	return nil
+}
+
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("invalid email address %q: %w", email, err)
+	}
+	if addr.Name != "" || addr.Address != email {
+		return fmt.Errorf("invalid email address %q, expected bare address (without name)", email)
+	}
+	return nil
+}
+
+func ValidateCondition(c *configv2.Condition) error {
+	switch c.GetMode() {
+	case configv2.ConditionMode_CONDITION_MODE_SHELL_PATTERN:
+		if _, err := path.Match(c.GetValue(), ""); err != nil {
+			return fmt.Errorf("invalid shell pattern %q: %w", c.GetValue(), err)
+		}
+	case configv2.ConditionMode_CONDITION_MODE_REGEXP:
+		if _, err := regexp.Compile(c.GetValue()); err != nil {
+			return fmt.Errorf("invalid regexp %q: %w", c.GetValue(), err)
+		}
+	case configv2.ConditionMode_CONDITION_MODE_UNSPECIFIED, configv2.ConditionMode_CONDITION_MODE_PREFIX, configv2.ConditionMode_CONDITION_MODE_SUFFIX, configv2.ConditionMode_CONDITION_MODE_FULL:
+		break
+	default:
+		return errors.New("unknown condition mode")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package identity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
+	"github.com/daishe/gitidentity/internal/gitinfo"
+	"github.com/daishe/gitidentity/internal/runcmd"
+)
+
+// resolvedValues returns values to apply for identity: values with templates expanded and values resolved from external commands
+func resolvedValues(ctx context.Context, i *configv2.Identity, info *gitinfo.GitInfo, bindings Bindings, autoSelected bool) (map[string]string, error) {
+	values, err := expandedValues(i, info, bindings, autoSelected)
+	if err != nil {
+		return nil, err
+	}
+	if len(i.GetValuesFrom()) == 0 {
+		return values, nil
+	}
+	resolved := make(map[string]string, len(values)+len(i.GetValuesFrom()))
+	maps.Copy(resolved, values)
+	for _, k := range slices.Sorted(maps.Keys(i.GetValuesFrom())) {
+		v, err := resolveValue(ctx, i.GetValuesFrom()[k])
+		if err != nil {
+			return nil, fmt.Errorf("identity %q: resolving value of %q: %w", IdentityAsString(i), k, err)
+		}
+		runcmd.AddSecret(v)
+		resolved[k] = v
+	}
+	return resolved, nil
+}
+
+func resolveValue(ctx context.Context, vs *configv2.ValueSource) (string, error) {
+	var cmd []string
+	var output *configv2.Condition
+	allowNonZeroExitCode := false
+	switch s := vs.GetSource().(type) {
+	case *configv2.ValueSource_Command:
+		cmd = append([]string{s.Command.GetCmd()}, s.Command.GetArgs()...)
+		output, allowNonZeroExitCode = s.Command.GetOutput(), s.Command.GetAllowNonZeroExitCode()
+	case *configv2.ValueSource_ShellScript:
+		cmd = append(getAutoMatchShell(), s.ShellScript.GetContent())
+		output, allowNonZeroExitCode = s.ShellScript.GetOutput(), s.ShellScript.GetAllowNonZeroExitCode()
+	default:
+		return "", errors.New("no value source specified")
+	}
+	if cmd[0] == "" {
+		return "", errors.New("empty command")
+	}
+
+	out, err := runcmd.CommandCombinedOutput(runcmd.WithSecretOutput(ctx), cmd[0], cmd[1:]...)
+	if ee := (&exec.ExitError{}); errors.As(err, &ee) && allowNonZeroExitCode {
+		err = nil
+	}
+	if err != nil {
+		return "", runcmd.CommandError(strings.Join(cmd, " "), nil, err) // output omitted, as it may contain the secret
+	}
+	v := strings.TrimSpace(string(out))
+	if output != nil {
+		verdict, _, err := condition(output, v)
+		if err != nil {
+			return "", fmt.Errorf("matching output: %w", err)
+		}
+		if !verdict {
+			return "", errors.New("output does not satisfy output condition")
+		}
+	}
+	return v, nil
+}
@@ -14,6 +14,7 @@ import (
 
 const (
 	GitLastAppliedKey         = "gitidentity.lastAppliedIdentity"
+	GitPreviousValuesKey      = "gitidentity.previousValues"
 	GitNameKey                = "user.name"
 	GitEmailKey               = "user.email"
 	GitCoreSshCommand         = "core.sshCommand"
@@ -62,6 +63,53 @@ func GitNameAndEmail(ctx context.Context, out map[string]string, local FlagLocal
 	return nil
 }
 
+type GitConfigEntry struct {
+	Origin string // file the entry comes from, empty when not a file
+	Key    string
+	Value  string
+}
+
+func ListGitConfigValues(ctx context.Context, local FlagLocalState, global FlagGlobalState) ([]GitConfigEntry, error) {
+	args := make([]string, 0, 6)
+	args = append(args, "config", "--list", "-z", "--show-origin")
+	if local {
+		args = append(args, "--local")
+	}
+	if global {
+		args = append(args, "--global")
+	}
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
+	if err != nil {
+		return nil, CommandError("git config --list ...", out, err)
+	}
+	return parseGitConfigList(out), nil
+}
+
+func ListGitConfigFileValues(ctx context.Context, file string) ([]GitConfigEntry, error) {
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), "config", "--list", "-z", "--show-origin", "--file", file)
+	if err != nil {
+		return nil, CommandError(fmt.Sprintf("git config --file %s --list ...", file), out, err)
+	}
+	return parseGitConfigList(out), nil
+}
+
+func parseGitConfigList(out []byte) []GitConfigEntry {
+	fields := strings.Split(string(out), "\x00")
+	entries := make([]GitConfigEntry, 0, len(fields)/2)
+	for idx := 0; idx+1 < len(fields); idx += 2 { // origin and entry pairs
+		k, v, found := strings.Cut(fields[idx+1], "\n")
+		if !found {
+			v = "true" // key without value is an implicit boolean true
+		}
+		origin, _ := strings.CutPrefix(fields[idx], "file:")
+		if origin == fields[idx] {
+			origin = ""
+		}
+		entries = append(entries, GitConfigEntry{Origin: origin, Key: k, Value: v})
+	}
+	return entries
+}
+
 func GetGitConfigValue(ctx context.Context, value string, local FlagLocalState, global FlagGlobalState) (string, bool, error) {
 	args := make([]string, 0, 5)
 	args = append(args, "config", "--get")
@@ -82,6 +130,27 @@ func GetGitConfigValue(ctx context.Context, value string, local FlagLocalState,
 	return strings.TrimSpace(string(out)), true, nil
 }
 
+func GetAllGitConfigValues(ctx context.Context, key string, local FlagLocalState, global FlagGlobalState) ([]string, error) {
+	args := make([]string, 0, 6)
+	args = append(args, "config", "--get-all", "-z")
+	if local {
+		args = append(args, "--local")
+	}
+	if global {
+		args = append(args, "--global")
+	}
+	args = append(args, key)
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
+	if ee := (&exec.ExitError{}); errors.As(err, &ee) && ee.ExitCode() == 1 { // the key is not set
+		return nil, nil
+	}
+	if err != nil {
+		return nil, CommandError(fmt.Sprintf("git config %s ...", key), out, err)
+	}
+	values := strings.Split(string(out), "\x00")
+	return values[:len(values)-1], nil // output is terminated with NUL
+}
+
 func SetGitConfigValue(ctx context.Context, key, to string) error {
 	if to == "" {
 		return UnsetGitConfigValue(ctx, key)
@@ -94,6 +163,34 @@ func SetGitConfigValue(ctx context.Context, key, to string) error {
 	return nil
 }
 
+// SetGitConfigEmptyValue replaces all values of the key with single empty value, overriding values from other scopes
+func SetGitConfigEmptyValue(ctx context.Context, key string) error {
+	args := []string{"config", "--local", "--replace-all", key, ""}
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
+	if err != nil {
+		return CommandError(fmt.Sprintf("git config %s ...", key), out, err)
+	}
+	return nil
+}
+
+func AddGitConfigValue(ctx context.Context, key, value string) error {
+	args := []string{"config", "--local", "--add", key, value}
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
+	if err != nil {
+		return CommandError(fmt.Sprintf("git config %s ...", key), out, err)
+	}
+	return nil
+}
+
+func AddGitConfigFileValue(ctx context.Context, file, key, value string) error {
+	args := []string{"config", "--file", file, "--add", key, value}
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
+	if err != nil {
+		return CommandError(fmt.Sprintf("git config --file %s %s ...", file, key), out, err)
+	}
+	return nil
+}
+
 func UnsetGitConfigValue(ctx context.Context, key string) error {
 	args := []string{"config", "--local", "--unset", key}
 	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
@@ -106,8 +203,22 @@ func UnsetGitConfigValue(ctx context.Context, key string) error {
 	return nil
 }
 
+func UnsetAllGitConfigValues(ctx context.Context, key string) error {
+	args := []string{"config", "--local", "--unset-all", key}
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), args...)
+	if ee := (&exec.ExitError{}); errors.As(err, &ee) && ee.ExitCode() == 5 { // unset of an option which does not exist
+		return nil
+	}
+	if err != nil {
+		return CommandError(fmt.Sprintf("git config %s ...", key), out, err)
+	}
+	return nil
+}
+
 func GitInfoFromDir(ctx context.Context) (*gitinfo.GitInfo, error) {
 	gi := &gitinfo.GitInfo{}
+	gi.Dir = gitTopLevelDir(ctx)
+	gi.ResolveBranch = func() string { return gitCurrentBranch(ctx) }
 
 	remotes, err := listGitRemotes(ctx)
 	if err != nil {
@@ -128,6 +239,22 @@ func GitInfoFromDir(ctx context.Context) (*gitinfo.GitInfo, error) {
 	return gi, nil
 }
 
+func gitTopLevelDir(ctx context.Context) string {
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "" // e.g. bare repository
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func gitCurrentBranch(ctx context.Context) string {
+	out, err := CommandCombinedOutput(ctx, GitExecutable(), "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return "" // e.g. detached HEAD
+	}
+	return strings.TrimSpace(string(out))
+}
+
 func listGitRemotes(ctx context.Context) ([]string, error) {
 	out, err := CommandCombinedOutput(ctx, GitExecutable(), "remote", "-v")
 	if err != nil {
@@ -7,10 +7,112 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/daishe/gitidentity/internal/logging"
 )
 
+type dirKey struct{}
+
+type secretOutputKey struct{}
+
+var (
+	secretsMu sync.Mutex
+	secrets   []string
+)
+
+// AddSecret registers value that must never be printed in debug logs
+func AddSecret(value string) {
+	if value == "" {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets = append(secrets, value)
+}
+
+// minSecretLength is the length from which secrets are redacted wherever they appear, shorter ones are redacted only when they make up a whole argument or output line (alone or as value following a key), as otherwise unrelated text would be redacted as well
+const minSecretLength = 8
+
+func isSecret(s string) bool {
+	s = strings.TrimPrefix(s, "--config=") // git config arguments, value follows the key
+	for _, secret := range secrets {
+		if s == secret {
+			return true
+		}
+		if _, v, ok := strings.Cut(s, "="); ok && v == secret { // key=value argument
+			return true
+		}
+		if _, v, ok := strings.Cut(s, " "); ok && v == secret { // key value output line
+			return true
+		}
+	}
+	return false
+}
+
+func redact(s string) string {
+	if isSecret(strings.TrimSpace(s)) {
+		return "<redacted>"
+	}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			s = strings.ReplaceAll(s, secret, "<redacted>")
+		}
+	}
+	return s
+}
+
+func redactArgs(args []string) []string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	redacted := make([]string, len(args))
+	for idx, a := range args {
+		redacted[idx] = redact(a)
+	}
+	return redacted
+}
+
+func redactLines(s string) string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	lines := strings.Split(s, "\n")
+	for idx, l := range lines {
+		lines[idx] = redact(l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WithSecretOutput marks output of commands run with returned context as secret, so that it is not printed in debug logs
+func WithSecretOutput(ctx context.Context) context.Context {
+	return context.WithValue(ctx, secretOutputKey{}, true)
+}
+
+func loggedOutput(ctx context.Context, out []byte) string {
+	if secret, _ := ctx.Value(secretOutputKey{}).(bool); secret {
+		return "<redacted>"
+	}
+	return redactLines(string(out))
+}
+
+func loggedCommand(cmd string, args []string) string {
+	return cmd + " " + strings.Join(redactArgs(args), " ")
+}
+
+func WithDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, dirKey{}, dir)
+}
+
+func dirFromContext(ctx context.Context) string {
+	dir, _ := ctx.Value(dirKey{}).(string)
+	return dir
+}
+
+func command(ctx context.Context, cmd string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, cmd, args...)
+	c.Dir = dirFromContext(ctx)
+	return c
+}
+
 func CommandError(cmd string, out []byte, err error) error {
 	if ee := (&exec.ExitError{}); errors.As(err, &ee) {
 		err = fmt.Errorf("command %q returned non zero exit code %d", cmd, ee.ExitCode())
@@ -24,23 +126,23 @@ func CommandError(cmd string, out []byte, err error) error {
 }
 
 func CommandCombinedOutput(ctx context.Context, cmd string, args ...string) ([]byte, error) {
-	logging.Log.Printf("COMMAND: %s %s", cmd, strings.Join(args, " "))
-	out, err := exec.CommandContext(ctx, cmd, args...).CombinedOutput()
+	logging.Log.Printf("COMMAND: %s", loggedCommand(cmd, args))
+	out, err := command(ctx, cmd, args...).CombinedOutput()
 	if ee := (&exec.ExitError{}); errors.As(err, &ee) {
-		logging.Log.Printf("COMMAND FAILED (NON ZERO EXIT CODE): %s %s, err: %v, output: %q", cmd, strings.Join(args, " "), err, string(out))
+		logging.Log.Printf("COMMAND FAILED (NON ZERO EXIT CODE): %s, err: %v, output: %q", loggedCommand(cmd, args), err, loggedOutput(ctx, out))
 		return out, err
 	}
 	if err != nil {
-		logging.Log.Printf("COMMAND FAILED: %s %s, err: %v, output: %q", cmd, strings.Join(args, " "), err, string(out))
+		logging.Log.Printf("COMMAND FAILED: %s, err: %v, output: %q", loggedCommand(cmd, args), err, loggedOutput(ctx, out))
 		return out, err
 	}
-	logging.Log.Printf("COMMAND RETURNED: %s %s, output: %q", cmd, strings.Join(args, " "), string(out))
+	logging.Log.Printf("COMMAND RETURNED: %s, output: %q", loggedCommand(cmd, args), loggedOutput(ctx, out))
 	return out, nil
 }
 
 func CommandPipeOutputAndInput(ctx context.Context, cmd string, args ...string) error {
-	logging.Log.Printf("COMMAND: %s %s", cmd, strings.Join(args, " "))
-	c := exec.CommandContext(ctx, cmd, args...)
+	logging.Log.Printf("COMMAND: %s", loggedCommand(cmd, args))
+	c := command(ctx, cmd, args...)
 	c.Stdin = os.Stdin
 	c.Stdout = os.Stdout
 	c.Stderr = os.Stderr
@@ -49,9 +151,9 @@ func CommandPipeOutputAndInput(ctx context.Context, cmd string, args ...string)
 		os.Exit(ee.ExitCode())
 	}
 	if err != nil {
-		logging.Log.Printf("COMMAND FAILED: %s %s, err: %v", cmd, strings.Join(args, " "), err)
+		logging.Log.Printf("COMMAND FAILED: %s, err: %v", loggedCommand(cmd, args), err)
 		return err
 	}
-	logging.Log.Printf("COMMAND RETURNED: %s %s", cmd, strings.Join(args, " "))
+	logging.Log.Printf("COMMAND RETURNED: %s", loggedCommand(cmd, args))
 	return nil
 }
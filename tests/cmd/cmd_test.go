@@ -3,9 +3,13 @@
 package cmd_test
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 
 	configv2 "github.com/daishe/gitidentity/api/gitidentity/config/v2"
@@ -23,6 +27,105 @@ func TestAdd(t *testing.T) {
 	td.MustRunGitIdentity("--config", td.FilePath("config.json"), "add", "--id", identityB.GetIdentifier(), "--name", identityB.GetValues()["user.name"], "--email", identityB.GetValues()["user.email"], "--value", "tmp.test="+identityB.GetValues()["tmp.test"])
 
 	require.Empty(t, Diff(ConfigV2(identityA, identityB), MustUnmarshalJSON(t, td.MustReadFile("config.json"), &configv2.Config{})))
+
+	output, err := td.RunGitIdentity("--config", td.FilePath("config.json"), "add", "--id", identityA.GetIdentifier(), "--name", identityA.GetValues()["user.name"], "--email", identityA.GetValues()["user.email"])
+	require.Error(t, err)
+	require.Contains(t, string(output), "is already defined in")
+	require.Empty(t, Diff(ConfigV2(identityA, identityB), MustUnmarshalJSON(t, td.MustReadFile("config.json"), &configv2.Config{})))
+}
+
+func TestAddInteractive(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+	td.Setenv("GIT_CONFIG_GLOBAL", td.FilePath("home/.gitconfig"))
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2()))
+
+	current := NewIdentityV2()
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.name", current.GetValues()["user.name"])
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email", current.GetValues()["user.email"])
+
+	input := strings.Join([]string{
+		"current",   // pre-fill from current repository
+		"",          // generated identifier
+		"",          // pre-filled name
+		"",          // pre-filled email
+		"bad key=1", // rejected, invalid key
+		"tmp.test=test",
+		"",           // no more extra values
+		"y",          // add auto apply rule
+		"remote url", // rule subject, exact label
+		"fix",        // rejected, ambiguous condition mode
+		"regexp",     // condition mode
+		"(",          // rejected, invalid regexp
+		"^ssh://",
+		"",  // no more auto apply rules
+		"y", // confirm
+	}, "\n") + "\n"
+	output := td.MustRunGitIdentityWithInput([]byte(input), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "add")
+	require.Contains(t, string(output), `invalid git config key "bad key"`)
+	require.Contains(t, string(output), "error parsing regexp")
+	require.Contains(t, string(output), `ambiguous selection "fix", matching: prefix, suffix`)
+
+	want := &configv2.Identity{
+		Values: map[string]string{"user.name": current.GetValues()["user.name"], "user.email": current.GetValues()["user.email"], "tmp.test": "test"},
+		AutoApplyWhen: []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+			Url: &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_REGEXP, Value: "^ssh://"},
+		}}}}}},
+	}
+	require.Empty(t, Diff(ConfigV2(want), MustUnmarshalJSON(t, td.MustReadFile("config.json"), &configv2.Config{})))
+}
+
+func TestAddFromRepo(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.Values["commit.gpgsign"] = "true"
+	identity.AutoApplyWhen = []*configv2.MatchList{RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_FULL, "ssh://git@example.com/user/example-repo.git")}
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+	for k, v := range identity.GetValues() {
+		td.MustRunGit("-C", td.FilePath("repo"), "config", k, v)
+	}
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "core.autocrlf", "false")
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "add", "--from-repo", "--keys", "user.*,commit.gpgsign", "--auto-apply", "--id", identity.GetIdentifier())
+
+	want := ConfigV2(identity)
+	want.Version = "v3" // new configuration files are created in current version
+	require.Empty(t, Diff(want, MustUnmarshalJSON(t, td.MustReadFile("config.json"), &configv2.Config{})))
+}
+
+func TestImportScan(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identityA := NewIdentityV2()
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA)))
+
+	identityB := NewIdentityV2()
+	identityB.Identifier = "" // imported identities have no identifier
+	identityB.AutoApplyWhen = []*configv2.MatchList{RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_PREFIX, "ssh://git@example.com/user/")}
+
+	for _, repo := range []string{"scan/a", "scan/nested/b"} {
+		td.MustMkdirAll(repo)
+		td.MustRunGit("-C", td.FilePath(repo), "init")
+		td.MustRunGit("-C", td.FilePath(repo), "remote", "add", "origin", "ssh://git@example.com/user/"+uuid.NewString()+".git")
+		td.MustRunGit("-C", td.FilePath(repo), "config", "user.name", identityB.GetValues()["user.name"])
+		td.MustRunGit("-C", td.FilePath(repo), "config", "user.email", identityB.GetValues()["user.email"])
+	}
+	td.MustMkdirAll("scan/c")
+	td.MustRunGit("-C", td.FilePath("scan/c"), "init")
+	td.MustRunGit("-C", td.FilePath("scan/c"), "config", "user.name", identityA.GetValues()["user.name"])
+	td.MustRunGit("-C", td.FilePath("scan/c"), "config", "user.email", identityA.GetValues()["user.email"])
+
+	td.MustRunGitIdentity("--config", td.FilePath("config.json"), "import", "--scan", td.FilePath("scan"), "--yes")
+
+	require.Empty(t, Diff(ConfigV2(identityA, identityB), MustUnmarshalJSON(t, td.MustReadFile("config.json"), &configv2.Config{})))
 }
 
 func TestV1CurrentJSON(t *testing.T) {
@@ -164,3 +267,993 @@ func TestSet(t *testing.T) {
 	outputJSON = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
 	require.Empty(t, Diff(identityA, MustUnmarshalJSON(t, outputJSON, &configv2.Identity{})))
 }
+
+func TestExportGitconfig(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.AutoApplyWhen = []*configv2.MatchList{
+		RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_PREFIX, "ssh://git@example.com/user/"),
+		EnvRule("GITIDENTITY_TEST"), // cannot be expressed natively
+		{Match: []*configv2.Match{{Subject: &configv2.Match_Expression{Expression: &configv2.MatchExpression{Expression: `branch == "main"`}}}}},
+	}
+	overlapping := NewIdentityV2() // matches the same repositories, but later in auto apply order
+	overlapping.AutoApplyWhen = []*configv2.MatchList{RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_PREFIX, "ssh://git@example.com/")}
+	disabled := NewIdentityV2()
+	disabled.Disabled = true
+	templated := NewIdentityV2()
+	templated.ExpandValues = true
+	cfg := ConfigV2(identity, overlapping, disabled, templated)
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	output := td.MustRunGitIdentity("--config", td.FilePath("config.json"), "export", "--gitconfig", td.FilePath("export"))
+	require.Contains(t, string(output), "skipped rule #2: environment variable rules cannot be expressed natively")
+	require.Contains(t, string(output), "skipped rule #3: expression rules cannot be expressed natively")
+	require.Contains(t, string(output), disabled.GetIdentifier()+": skipped as it is disabled")
+	require.Contains(t, string(output), templated.GetIdentifier()+": skipped as its values are expanded")
+	require.NotContains(t, string(output), "Exported "+disabled.GetIdentifier())
+	require.NotContains(t, string(output), "Exported "+templated.GetIdentifier())
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+	td.Setenv("GIT_CONFIG_GLOBAL", td.FilePath("export", "includes.gitconfig"))
+	email := td.MustRunGit("-C", td.FilePath("repo"), "config", "user.email")
+	require.Empty(t, Diff(identity.GetValues()["user.email"], strings.TrimSpace(string(email))))
+}
+
+func TestExtends(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	base := &configv2.Identity{
+		Identifier: "base",
+		Abstract:   true,
+		Values:     map[string]string{"commit.gpgsign": "true", "user.name": "base"},
+	}
+	identity := NewIdentityV2()
+	identity.Extends = []string{base.GetIdentifier()}
+	cfg := ConfigV2(base, identity)
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	searchQuery := []byte(identity.GetIdentifier() + "\n")
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+
+	want := NewIdentityV2()
+	want.Identifier = identity.GetIdentifier()
+	want.Values = map[string]string{"commit.gpgsign": "true", "user.name": identity.GetValues()["user.name"], "user.email": identity.GetValues()["user.email"]}
+	outputJSON := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Empty(t, Diff(want, MustUnmarshalJSON(t, outputJSON, &configv2.Identity{})))
+	require.Empty(t, Diff("true", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "commit.gpgsign")))))
+
+	cfg.Version = "v2"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	_, err := td.RunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err)
+}
+
+func TestExtendsAcrossFiles(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	base := &configv2.Identity{
+		Identifier: "company",
+		Abstract:   true,
+		Values:     map[string]string{"commit.gpgsign": "true"},
+	}
+	baseCfg := ConfigV2(base)
+	baseCfg.Version = "v3"
+	td.MustWriteFile("conf.d/company.yaml", MustMarshalYAML(t, baseCfg))
+	identity := NewIdentityV2()
+	identity.Extends = []string{base.GetIdentifier()}
+	cfg := ConfigV2(identity)
+	cfg.Version = "v3"
+	cfg.Profiles = map[string]*configv2.Profile{"work": {List: []*configv2.Identity{{Identifier: "work", Extends: []string{base.GetIdentifier()}, Values: map[string]string{"user.name": "work", "user.email": "work@example.com"}}}}}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	td.MustRunGitIdentityWithInput([]byte(identity.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Empty(t, Diff("true", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "commit.gpgsign")))))
+	require.Empty(t, Diff(identity.GetValues()["user.email"], strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email")))))
+
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--unset", "commit.gpgsign")
+	td.MustRunGitIdentityWithInput([]byte("work\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "--profile", "work", "set")
+	require.Empty(t, Diff("true", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "commit.gpgsign")))))
+
+	td.MustRemove("conf.d/company.yaml")
+	_, err := td.RunGitIdentityWithInput([]byte(identity.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err)
+}
+
+func TestDefaults(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.Values["commit.gpgsign"] = ""
+	cfg := ConfigV2(identity)
+	cfg.Defaults = map[string]string{"user.useconfigonly": "true", "commit.gpgsign": "true"}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	searchQuery := []byte(identity.GetIdentifier() + "\n")
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+
+	require.Empty(t, Diff("true", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.useconfigonly")))))
+	_, err := td.RunGit("-C", td.FilePath("repo"), "config", "--local", "commit.gpgsign")
+	require.Error(t, err)
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "unset")
+	_, err = td.RunGit("-C", td.FilePath("repo"), "config", "--local", "user.useconfigonly")
+	require.Error(t, err)
+}
+
+func TestIncludes(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identityA := NewIdentityV2()
+	mainCfg := ConfigV2(identityA)
+	mainCfg.Include = []string{"extra/*.yaml"}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, mainCfg))
+	identityB := NewIdentityV2()
+	td.MustMkdirAll("extra")
+	td.MustWriteFile("extra/b.yaml", MustMarshalYAML(t, ConfigV2(identityB)))
+	identityCV1, identityC := NewIdentityV1()
+	td.MustMkdirAll("conf.d")
+	td.MustWriteFile("conf.d/c.json", MustMarshalJSON(t, ConfigV1(identityCV1)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	for _, i := range []*configv2.Identity{identityA, identityB, identityC} {
+		searchQuery := []byte(i.GetIdentifier() + "\n")
+		td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+		output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=short")
+		require.Empty(t, Diff(i.GetIdentifier(), strings.TrimSpace(string(output))))
+	}
+
+	identityD := NewIdentityV2()
+	td.MustRunGitIdentity("--config", td.FilePath("config.json"), "add", "--file", td.FilePath("extra", "b.yaml"), "--id", identityD.GetIdentifier(), "--name", identityD.GetValues()["user.name"], "--email", identityD.GetValues()["user.email"])
+	require.Empty(t, Diff(ConfigV2(identityB, identityD), MustUnmarshalYAML(t, td.MustReadFile("extra/b.yaml"), &configv2.Config{})))
+	_, err := td.RunGitIdentity("--config", td.FilePath("config.json"), "add", "--id", identityD.GetIdentifier(), "--name", identityD.GetValues()["user.name"], "--email", identityD.GetValues()["user.email"])
+	require.Error(t, err)
+	output, err := td.RunGitIdentity("--config", td.FilePath("config.json"), "add", "--file", td.FilePath("extra", "b.yaml"), "--id", identityD.GetIdentifier(), "--name", identityD.GetValues()["user.name"], "--email", identityD.GetValues()["user.email"])
+	require.Error(t, err)
+	require.Contains(t, string(output), "is already defined in")
+	require.Empty(t, Diff(ConfigV2(identityB, identityD), MustUnmarshalYAML(t, td.MustReadFile("extra/b.yaml"), &configv2.Config{})))
+
+	td.MustWriteFile("conf.d/duplicate.json", MustMarshalJSON(t, ConfigV2(identityA)))
+	_, err = td.RunGitIdentityWithInput([]byte(identityA.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err)
+}
+
+func TestLayers(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+	td.Setenv("XDG_CONFIG_HOME", td.FilePath("user"))
+	td.Setenv("XDG_CONFIG_DIRS", td.FilePath("system"))
+
+	identityS := NewIdentityV2()
+	identityU := NewIdentityV2()
+	overridden := NewIdentityV2()
+	overridden.Identifier = identityU.GetIdentifier()
+	td.MustMkdirAll("system/gitidentity")
+	td.MustWriteFile("system/gitidentity/config.yaml", MustMarshalYAML(t, ConfigV2(identityS, overridden)))
+	td.MustMkdirAll("user/gitidentity")
+	td.MustWriteFile("user/gitidentity/config.json", MustMarshalJSON(t, ConfigV2(identityU)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	td.MustRunGitIdentityWithInput([]byte(identityS.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "set")
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "current", "--format=long")
+	require.Contains(t, string(output), "Layer:    system")
+
+	td.MustRunGitIdentityWithInput([]byte(identityU.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "set")
+	outputJSON := td.MustRunGitIdentity("-C", td.FilePath("repo"), "current", "--format=json")
+	require.Empty(t, Diff(identityU, MustUnmarshalJSON(t, outputJSON, &configv2.Identity{})))
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "current", "--format=long")
+	require.Contains(t, string(output), "Layer:    user")
+
+	identityO := NewIdentityV2()
+	td.MustWriteFile("other.json", MustMarshalJSON(t, ConfigV2(identityO)))
+	td.Setenv("GITIDENTITY_CONFIG", td.FilePath("other.json"))
+	td.MustRunGitIdentityWithInput([]byte(identityO.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "set")
+	_, err := td.RunGitIdentityWithInput([]byte(identityS.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "set")
+	require.Error(t, err)
+
+	identityD := NewIdentityV2()
+	td.MustWriteFile(".gitidentity.yaml", MustMarshalYAML(t, ConfigV2(identityD)))
+	td.MustRunGitIdentityWithInput([]byte(identityD.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "set") // directory layer is loaded with explicit configuration path
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "current", "--format=long")
+	require.Contains(t, string(output), "Layer:    directory")
+}
+
+func TestDirectoryConfig(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identityA := NewIdentityV2()
+	identityB := NewIdentityV2()
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA, identityB)))
+
+	td.MustMkdirAll("work/team/repo")
+	td.MustRunGit("-C", td.FilePath("work/team/repo"), "init")
+	workDir, err := filepath.EvalSymlinks(td.FilePath("work")) // git reports resolved paths
+	require.NoError(t, err)
+	locationRule := func(dir string) []*configv2.MatchList {
+		return []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Location{Location: &configv2.MatchLocation{
+			Path: &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_PREFIX, Value: dir + "/"},
+		}}}}}}
+	}
+	td.MustWriteFile("work/.gitidentity.yaml", MustMarshalYAML(t, ConfigV2(&configv2.Identity{Identifier: identityA.GetIdentifier(), AutoApplyWhen: locationRule(workDir)})))
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "--debug", "set", "--only-auto")
+	require.Contains(t, string(output), "using directory layer file \""+td.FilePath("work", ".gitidentity.yaml")+"\"")
+	outputJSON := td.MustRunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Empty(t, Diff(&configv2.Identity{Identifier: identityA.GetIdentifier(), Values: identityA.GetValues(), AutoApplyWhen: locationRule(workDir)}, MustUnmarshalJSON(t, outputJSON, &configv2.Identity{})))
+
+	td.MustWriteFile("work/team/.gitidentity.yaml", MustMarshalYAML(t, ConfigV2(&configv2.Identity{Identifier: identityB.GetIdentifier(), AutoApplyWhen: locationRule(workDir)})))
+	td.MustRunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	outputJSON = td.MustRunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Empty(t, Diff(&configv2.Identity{Identifier: identityB.GetIdentifier(), Values: identityB.GetValues(), AutoApplyWhen: locationRule(workDir)}, MustUnmarshalJSON(t, outputJSON, &configv2.Identity{})))
+	td.MustRemove("work/team/.gitidentity.yaml")
+
+	// fields not set in directory configuration are kept from user configuration
+	identityA.Disabled = true
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA, identityB)))
+	_, err = td.RunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	identityA.Disabled = false
+	identityA.ExpiresAt = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA, identityB)))
+	_, err = td.RunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	identityA.ExpiresAt = ""
+	identityA.ExpandValues = true
+	identityA.Values["tmp.dir"] = "${repo.dir}"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA, identityB)))
+	td.MustRunGitIdentity("-C", td.FilePath("work/team/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Empty(t, Diff(filepath.Join(workDir, "team", "repo"), strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("work/team/repo"), "config", "--local", "tmp.dir")))))
+}
+
+func TestUntrustedDirectoryConfig(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2()))
+	td.MustMkdirAll("work/repo")
+	td.MustRunGit("-C", td.FilePath("work/repo"), "init")
+
+	malicious := NewIdentityV2()
+	malicious.Values["core.fsmonitor"] = "touch " + td.FilePath("PWNED2")
+	malicious.AutoApplyWhen = []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_ShellScript{ShellScript: &configv2.MatchShellScript{
+		Content: "touch " + td.FilePath("PWNED"),
+	}}}}}}
+	td.MustWriteFile("work/repo/.gitidentity.yaml", MustMarshalYAML(t, ConfigV2(malicious))) // committed to the repository
+
+	output, err := td.RunGitIdentity("-C", td.FilePath("work/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "ignoring directory configuration")
+	_, err = td.RunGitIdentityWithInput([]byte(malicious.GetIdentifier()+"\n"), "-C", td.FilePath("work/repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err)
+	require.NoFileExists(t, td.FilePath("PWNED"))
+	_, err = td.RunGit("-C", td.FilePath("work/repo"), "config", "--local", "core.fsmonitor")
+	require.Error(t, err)
+
+	td.MustWriteFile("work/.gitidentity.yaml", MustMarshalYAML(t, ConfigV2(malicious))) // outside of the repository, but still untrusted
+	output, err = td.RunGitIdentity("-C", td.FilePath("work/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "untrusted directory configuration")
+	require.NoFileExists(t, td.FilePath("PWNED"))
+	td.MustRemove("work/.gitidentity.yaml")
+
+	repoDir, err := filepath.EvalSymlinks(td.FilePath("work/repo"))
+	require.NoError(t, err)
+	trusted := NewIdentityV2()
+	trusted.AutoApplyWhen = []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Location{Location: &configv2.MatchLocation{
+		Path: &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_FULL, Value: repoDir},
+	}}}}}}
+	td.MustWriteFile("work/repo/.gitidentity.yaml", MustMarshalYAML(t, ConfigV2(trusted)))
+	cfg := ConfigV2()
+	cfg.TrustedDirectories = []string{td.FilePath("work/repo")}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output = td.MustRunGitIdentity("-C", td.FilePath("work/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+trusted.GetIdentifier())
+}
+
+func TestRequirements(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identityA := NewIdentityV2()
+	identityA.AutoApplyWhen = []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Value: "example.com"},
+	}}}}}}
+	identityB := NewIdentityV2()
+	identityB.Values["user.email"] = strings.TrimSuffix(identityB.GetValues()["user.email"], "example.com") + "corp.example"
+	identityC := NewIdentityV2()
+	identityC.ExpandValues = true
+	identityC.Values["user.email"] = "c@${GITIDENTITY_TEST_DOMAIN}"
+	td.Setenv("GITIDENTITY_TEST_DOMAIN", "corp.example")
+	identityD := NewIdentityV2()
+	identityD.ValuesFrom = map[string]*configv2.ValueSource{
+		"user.email": {Source: &configv2.ValueSource_ShellScript{ShellScript: &configv2.MatchShellScript{Content: "printf d@corp.example"}}},
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA, identityB, identityC, identityD)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+	td.MustWriteFile("repo/.gitidentity-requirements.yaml", []byte("values:\n- key: user.email\n  value: {mode: CONDITION_MODE_SUFFIX, value: \"@corp.example\"}\n  reason: CLA signed with corporate email\n"))
+
+	output, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "does not satisfy repository requirements: user.email must end with \"@corp.example\"")
+
+	_, err = td.RunGitIdentityWithInput([]byte(identityA.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto")
+	require.Error(t, err)
+
+	td.MustRunGitIdentityWithInput([]byte(identityB.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=short")
+	require.Empty(t, Diff(identityB.GetIdentifier(), strings.TrimSpace(string(output))))
+
+	td.MustRunGitIdentityWithInput([]byte(identityC.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto")
+	require.Empty(t, Diff("c@corp.example", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email")))))
+
+	output = td.MustRunGitIdentityWithInput([]byte(identityD.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto")
+	require.Contains(t, string(output), "cannot be checked against repository requirements: user.email is resolved from command only when identity is applied")
+	require.Empty(t, Diff("d@corp.example", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email")))))
+}
+
+func TestExpandValues(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+	td.Setenv("GITIDENTITY_TEST_KEY", "work")
+
+	identity := NewIdentityV2()
+	identity.ExpandValues = true
+	identity.Values["tmp.key"] = "${GITIDENTITY_TEST_KEY}.pub"
+	identity.Values["tmp.remote"] = "${remote.owner}@${remote.host}"
+	identity.Values["tmp.dir"] = "${repo.dir}"
+	identity.Values["tmp.literal"] = "$HOME costs $5"
+	undefined := NewIdentityV2()
+	undefined.ExpandValues = true
+	undefined.Values["tmp.key"] = "${GITIDENTITY_TEST_UNDEFINED}.pub"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity, undefined)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "git@example.com:group/user/example-repo.git")
+	repoDir, err := filepath.EvalSymlinks(td.FilePath("repo")) // git reports resolved paths
+	require.NoError(t, err)
+
+	td.MustRunGitIdentityWithInput([]byte(identity.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	for k, want := range map[string]string{"tmp.key": "work.pub", "tmp.remote": "group/user@example.com", "tmp.dir": repoDir, "tmp.literal": "$HOME costs $5"} {
+		require.Empty(t, Diff(want, strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", k)))))
+	}
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=long")
+	require.Contains(t, string(output), "tmp.remote = group/user@example.com (template: ${remote.owner}@${remote.host})")
+
+	output, err = td.RunGitIdentityWithInput([]byte(undefined.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err)
+	require.Contains(t, string(output), `environment variable "GITIDENTITY_TEST_UNDEFINED" is not defined`)
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "unset")
+	_, err = td.RunGit("-C", td.FilePath("repo"), "config", "--local", "tmp.remote")
+	require.Error(t, err)
+}
+
+func TestValuesFrom(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identityA := NewIdentityV2()
+	identityA.ValuesFrom = map[string]*configv2.ValueSource{
+		"tmp.secret": {Source: &configv2.ValueSource_ShellScript{ShellScript: &configv2.MatchShellScript{Content: "printf '  s3cr3t-%s  ' value"}}},
+		"tmp.short":  {Source: &configv2.ValueSource_ShellScript{ShellScript: &configv2.MatchShellScript{Content: "printf local"}}},
+	}
+	identityB := NewIdentityV2()
+	identityB.ValuesFrom = map[string]*configv2.ValueSource{
+		"tmp.secret": {Source: &configv2.ValueSource_ShellScript{ShellScript: &configv2.MatchShellScript{Content: "exit 3"}}},
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identityA, identityB)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	output := td.MustRunGitIdentityWithInput([]byte(identityA.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "--debug", "set")
+	require.NotContains(t, string(output), "s3cr3t-value")
+	require.Contains(t, string(output), "tmp.short <redacted>")
+	require.NotContains(t, string(output), "--<redacted>") // short secrets are not redacted inside unrelated arguments
+	require.Empty(t, Diff("s3cr3t-value", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "tmp.secret")))))
+
+	_, err := td.RunGitIdentityWithInput([]byte(identityB.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err)
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=short")
+	require.Empty(t, Diff(identityA.GetIdentifier(), strings.TrimSpace(string(output))))
+	require.Empty(t, Diff("s3cr3t-value", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "tmp.secret")))))
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "unset")
+	_, err = td.RunGit("-C", td.FilePath("repo"), "config", "--local", "tmp.secret")
+	require.Error(t, err)
+}
+
+func TestCloneValuesFrom(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.AutoApplyWhen = []*configv2.MatchList{RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_SUFFIX, "upstream")}
+	identity.ValuesFrom = map[string]*configv2.ValueSource{
+		"tmp.secret": {Source: &configv2.ValueSource_ShellScript{ShellScript: &configv2.MatchShellScript{Content: "printf %s%s Zq 7x"}}}, // short secret, not present literally in the script
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	td.MustMkdirAll("upstream")
+	td.MustRunGit("-C", td.FilePath("upstream"), "init")
+	td.MustMkdirAll("clones")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("clones"), "--config", td.FilePath("config.json"), "--debug", "clone", "--only-auto", td.FilePath("upstream"))
+	require.NotContains(t, string(output), "Zq7x")
+	require.Contains(t, string(output), "tmp.secret <redacted>")
+	require.Empty(t, Diff("Zq7x", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("clones/upstream"), "config", "--local", "tmp.secret")))))
+}
+
+func TestCaptures(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := &configv2.Identity{
+		Identifier: "github-forks-" + uuid.NewString(),
+		Values:     map[string]string{"user.name": "${owner}", "user.email": "${owner}@users.noreply.github.com"},
+		AutoApplyWhen: []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+			Url: &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_REGEXP, Value: `github\.com[:/](?P<owner>[^/]+)/`},
+		}}}}}},
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "git@github.com:alice/example-repo.git")
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Empty(t, Diff("alice@users.noreply.github.com", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email")))))
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=short")
+	require.Empty(t, Diff(identity.GetIdentifier(), strings.TrimSpace(string(output))))
+
+	output, err := td.RunGitIdentityWithInput([]byte(identity.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "can only be applied automatically")
+}
+
+func TestPriority(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	rule := []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Value: "example.com"},
+	}}}}}}
+	identityZ := NewIdentityV2()
+	identityZ.Identifier = "z-" + identityZ.GetIdentifier()
+	identityZ.AutoApplyWhen = rule
+	identityA := NewIdentityV2()
+	identityA.Identifier = "a-" + identityA.GetIdentifier()
+	identityA.AutoApplyWhen = rule
+	cfg := ConfigV2(identityZ, identityA)
+	cfg.FirstMatchWins = true
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identityZ.GetIdentifier())
+	require.Contains(t, string(output), "Warning: configuration")
+
+	identityA.Priority = 1
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identityA.GetIdentifier())
+	require.NotContains(t, string(output), "Warning:")
+}
+
+func TestAmbiguousMatch(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	rule := []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Value: "example.com"},
+	}}}}}}
+	identityA := NewIdentityV2()
+	identityA.Identifier = "a-" + identityA.GetIdentifier()
+	identityA.AutoApplyWhen = rule
+	identityB := NewIdentityV2()
+	identityB.Identifier = "b-" + identityB.GetIdentifier()
+	identityB.AutoApplyWhen = rule
+	cfg := ConfigV2(identityA, identityB)
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+
+	output, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "ambiguous")
+	require.Contains(t, string(output), identityA.GetIdentifier())
+	require.Contains(t, string(output), identityB.GetIdentifier())
+
+	searchQuery := []byte(identityB.GetIdentifier() + "\n")
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Equal(t, identityB.GetValues()["user.email"], strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email"))))
+
+	cfg.FirstMatchWins = true
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identityA.GetIdentifier())
+}
+
+func TestCloneOnlyAuto(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.AutoApplyWhen = []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_SUFFIX, Value: "work-upstream"},
+	}}}}}}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	td.MustMkdirAll("work-upstream")
+	td.MustRunGit("-C", td.FilePath("work-upstream"), "init")
+	td.MustMkdirAll("other-upstream")
+	td.MustRunGit("-C", td.FilePath("other-upstream"), "init")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath(), "--config", td.FilePath("config.json"), "clone", "--only-auto", td.FilePath("work-upstream"), td.FilePath("work"))
+	require.Contains(t, string(output), "Automatically selected identity: "+identity.GetIdentifier())
+	require.Empty(t, Diff(identity.GetValues()["user.email"], strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("work"), "config", "--local", "user.email")))))
+
+	output, err := td.RunGitIdentity("-C", td.FilePath(), "--config", td.FilePath("config.json"), "clone", "--only-auto", td.FilePath("other-upstream"), td.FilePath("other"))
+	require.Error(t, err)
+	require.Contains(t, string(output), "no matching identity")
+	require.NoDirExists(t, td.FilePath("other"))
+}
+
+func TestUnsetValues(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.UnsetValues = []string{"commit.gpgsign", "credential.helper"}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "commit.gpgsign", "true")
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--add", "credential.helper", "store")
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--add", "credential.helper", "cache --timeout=60")
+
+	searchQuery := []byte(identity.GetIdentifier() + "\n")
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Equal(t, "false", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--type=bool", "commit.gpgsign"))))
+	require.Empty(t, strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--get-all", "credential.helper"))))
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "unset")
+	require.Equal(t, "true", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "commit.gpgsign"))))
+	require.Equal(t, "store\ncache --timeout=60", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--get-all", "credential.helper"))))
+	_, err := td.RunGit("-C", td.FilePath("repo"), "config", "gitidentity.previousValues")
+	require.Error(t, err)
+}
+
+func TestMultiValues(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.MultiValues = map[string]*configv2.ValueList{
+		"url.git@example.com:.insteadOf": {Values: []string{"https://example.com/", "git://example.com/"}},
+		"credential.helper":              {Values: []string{"", "identity-helper"}},
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--add", "credential.helper", "store")
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--add", "credential.helper", "cache --timeout=60")
+
+	searchQuery := []byte(identity.GetIdentifier() + "\n")
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Equal(t, "https://example.com/\ngit://example.com/", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--get-all", "url.git@example.com:.insteadOf"))))
+	outputJSON := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Empty(t, Diff(identity, MustUnmarshalJSON(t, outputJSON, &configv2.Identity{})))
+
+	require.Equal(t, "\nidentity-helper\n", string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--get-all", "credential.helper")))
+
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set") // reapplying does not duplicate values
+	require.Equal(t, "https://example.com/\ngit://example.com/", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--get-all", "url.git@example.com:.insteadOf"))))
+	require.Equal(t, "\nidentity-helper\n", string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--get-all", "credential.helper")))
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "unset")
+	_, err := td.RunGit("-C", td.FilePath("repo"), "config", "--get-all", "url.git@example.com:.insteadOf")
+	require.Error(t, err)
+	require.Equal(t, "store\ncache --timeout=60", strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "--get-all", "credential.helper"))))
+	_, err = td.RunGit("-C", td.FilePath("repo"), "config", "gitidentity.previousValues")
+	require.Error(t, err)
+}
+
+func TestTags(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	rule := []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Value: "example.com"},
+	}}}}}}
+	identityA := NewIdentityV2()
+	identityA.Description = "Acme consulting"
+	identityA.Tags = []string{"acme", "work"}
+	identityA.AutoApplyWhen = rule
+	identityB := NewIdentityV2()
+	identityB.Tags = []string{"personal"}
+	identityB.AutoApplyWhen = rule
+	cfg := ConfigV2(identityA, identityB)
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	td.MustRunGitIdentityWithInput([]byte("acme\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto") // search matches tags
+	require.Equal(t, identityA.GetValues()["user.email"], strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "user.email"))))
+
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "--tag", "personal", "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identityB.GetIdentifier())
+
+	output, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "--tag", "acme", "--tag", "personal", "set")
+	require.Error(t, err)
+	require.Contains(t, string(output), "no active identities tagged acme, personal")
+}
+
+func TestExpiry(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	rule := []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Value: "example.com"},
+	}}}}}}
+	identityDisabled := NewIdentityV2()
+	identityDisabled.Disabled = true
+	identityDisabled.AutoApplyWhen = rule
+	identityExpired := NewIdentityV2()
+	identityExpired.ExpiresAt = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	identityExpired.AutoApplyWhen = rule
+	identityExpiring := NewIdentityV2()
+	identityExpiring.ExpiresAt = time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+	identityExpiring.AutoApplyWhen = rule
+	cfg := ConfigV2(identityDisabled, identityExpired, identityExpiring)
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identityExpiring.GetIdentifier())
+	require.Contains(t, string(output), "Warning: identity \""+identityExpiring.GetIdentifier()+"\" expires at")
+
+	_, err := td.RunGitIdentityWithInput([]byte(identityExpired.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto")
+	require.Error(t, err)
+
+	expiredAt := identityExpired.GetExpiresAt()
+	identityExpired.ExpiresAt = time.Now().Add(time.Hour).Format(time.RFC3339)
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	td.MustRunGitIdentityWithInput([]byte(identityExpired.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--no-auto")
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current")
+	require.NotContains(t, string(output), "Warning:")
+
+	extendedAt := identityExpired.GetExpiresAt()
+	identityExpired.ExpiresAt = expiredAt // time passes until identity expires
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current")
+	require.Contains(t, string(output), "Warning: identity \""+identityExpired.GetIdentifier()+"\" used in repository expired at "+expiredAt)
+
+	snapshot := strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "gitidentity.lastAppliedIdentity")))
+	snapshot = strings.ReplaceAll(snapshot, extendedAt, expiredAt)
+	td.MustRunGit("-C", td.FilePath("repo"), "config", "--local", "gitidentity.lastAppliedIdentity", snapshot)
+	identityExpired.ExpiresAt = extendedAt // expiry extended in configuration takes precedence over the one recorded when applied
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current")
+	require.NotContains(t, string(output), "Warning:")
+
+	cfg.List = []*configv2.Identity{identityDisabled, identityExpiring} // recorded expiry is used once identity is removed from configuration
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current")
+	require.Contains(t, string(output), "Warning: identity \""+identityExpired.GetIdentifier()+"\" used in repository expired at "+expiredAt)
+}
+
+func TestProfiles(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identityShared := NewIdentityV2()
+	identityA := NewIdentityV2()
+	identityB := NewIdentityV2()
+	cfg := ConfigV2(identityShared)
+	cfg.Version = "v3"
+	cfg.Profiles = map[string]*configv2.Profile{
+		"client-a": {List: []*configv2.Identity{identityA}},
+		"client-b": {List: []*configv2.Identity{identityB}},
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	output := td.MustRunGitIdentity("--config", td.FilePath("config.json"), "profile", "list")
+	require.Equal(t, "client-a\nclient-b", strings.TrimSpace(string(output)))
+	_, err := td.RunGitIdentityWithInput([]byte(identityA.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	require.Error(t, err) // profile identities are not available without active profile
+
+	td.MustRunGitIdentity("--config", td.FilePath("config.json"), "profile", "use", "client-a")
+	output = td.MustRunGitIdentity("--config", td.FilePath("config.json"), "profile", "current")
+	require.Equal(t, "client-a", strings.TrimSpace(string(output)))
+	output = td.MustRunGitIdentity("--config", td.FilePath("config.json"), "profile", "list")
+	require.Equal(t, "client-a (active)\nclient-b", strings.TrimSpace(string(output)))
+
+	td.MustRunGitIdentityWithInput([]byte(identityA.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+	outputJSON := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Equal(t, "client-a", MustUnmarshalJSON(t, outputJSON, &configv2.Identity{}).GetProfile())
+
+	td.MustRunGitIdentityWithInput([]byte(identityB.GetIdentifier()+"\n"), "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "--profile", "client-b", "set")
+	outputJSON = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Equal(t, "client-b", MustUnmarshalJSON(t, outputJSON, &configv2.Identity{}).GetProfile())
+
+	_, err = td.RunGitIdentity("--config", td.FilePath("config.json"), "profile", "use", "client-c")
+	require.Error(t, err)
+	td.MustRunGitIdentity("--config", td.FilePath("config.json"), "profile", "use", "--none")
+	output = td.MustRunGitIdentity("--config", td.FilePath("config.json"), "profile", "current")
+	require.Empty(t, strings.TrimSpace(string(output)))
+}
+
+func TestSharedRules(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identitySigning := NewIdentityV2()
+	identitySigning.Priority = 1
+	identitySigning.AutoApplyWhen = []*configv2.MatchList{{
+		Rules: []string{"work"},
+		Match: []*configv2.Match{{Subject: &configv2.Match_Env{Env: &configv2.MatchEnv{Name: "GITIDENTITY_TEST_SIGNING", To: &configv2.Condition{Value: "1"}}}}},
+	}}
+	identityPlain := NewIdentityV2()
+	identityPlain.AutoApplyWhen = []*configv2.MatchList{{Rules: []string{"work"}}}
+	cfg := ConfigV2(identitySigning, identityPlain)
+	cfg.Version = "v3"
+	cfg.Rules = map[string]*configv2.MatchList{
+		"work": {Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+			Url: &configv2.Condition{Value: "example.com"},
+		}}}}},
+		"unused": {Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+			Url: &configv2.Condition{Value: "example.org"},
+		}}}}},
+	}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identityPlain.GetIdentifier())
+	require.Contains(t, string(output), "shared rules not referenced by any identity: unused")
+
+	td.Setenv("GITIDENTITY_TEST_SIGNING", "1")
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identitySigning.GetIdentifier())
+
+	identityPlain.AutoApplyWhen[0].Rules = []string{"missing"}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "shared rule \"missing\" does not exist")
+}
+
+func TestSharedRulesAcrossFiles(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	identity := NewIdentityV2()
+	identity.AutoApplyWhen = []*configv2.MatchList{{Rules: []string{"work"}}}
+	cfg := ConfigV2(identity)
+	cfg.Version = "v3"
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	rulesCfg := ConfigV2()
+	rulesCfg.Version = "v3"
+	rulesCfg.Rules = map[string]*configv2.MatchList{"work": RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_UNSPECIFIED, "example.com")}
+	td.MustMkdirAll("conf.d")
+	td.MustWriteFile("conf.d/rules.json", MustMarshalJSON(t, rulesCfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identity.GetIdentifier())
+	require.NotContains(t, string(output), "shared rules not referenced by any identity")
+
+	otherRulesCfg := ConfigV2()
+	otherRulesCfg.Version = "v3"
+	otherRulesCfg.Rules = map[string]*configv2.MatchList{"work": RemoteURLRule(configv2.ConditionMode_CONDITION_MODE_UNSPECIFIED, "example.org")}
+	td.MustWriteFile("conf.d/other.json", MustMarshalJSON(t, otherRulesCfg))
+	output, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "shared rule \"work\" is defined differently")
+}
+
+func TestExpression(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	expression := func(expr string) []*configv2.MatchList {
+		return []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Expression{Expression: &configv2.MatchExpression{Expression: expr}}}}}}
+	}
+	identity := NewIdentityV2()
+	identity.AutoApplyWhen = expression(`remotes.exists(r, r.url.contains("example.com")) && !remotes.exists(r, r.name == "upstream") && branch == "work" && os != "" && path != ""`)
+	cfg := ConfigV2(identity)
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init", "--initial-branch=work")
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "origin", "ssh://git@example.com/user/example-repo.git")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Contains(t, string(output), "Automatically selected identity: "+identity.GetIdentifier())
+
+	td.MustRunGit("-C", td.FilePath("repo"), "remote", "add", "upstream", "ssh://git@example.org/user/example-repo.git")
+	_, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+
+	identity.AutoApplyWhen = expression(`remotes.size()`)
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output, err = td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "identity \""+identity.GetIdentifier()+"\": compiling expression")
+
+	identity.AutoApplyWhen = expression(`remotes.exists(r, r.unknown(`)
+	td.MustWriteFile("config.json", MustMarshalJSON(t, cfg))
+	output, err = td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	require.Error(t, err)
+	require.Contains(t, string(output), "identity \""+identity.GetIdentifier()+"\": compiling expression")
+}
+
+func TestRepositoryMatch(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+
+	full := func(v string) *configv2.Condition {
+		return &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_FULL, Value: v}
+	}
+	identity := NewIdentityV2()
+	identity.AutoApplyWhen = []*configv2.MatchList{{Match: []*configv2.Match{{Subject: &configv2.Match_Repository{Repository: &configv2.MatchRepository{
+		RemoteName: full("origin"),
+		Host:       full("gitlab.com"),
+		Port:       full("22"),
+		Owner:      &configv2.Condition{Mode: configv2.ConditionMode_CONDITION_MODE_PREFIX, Value: "org/"},
+		Name:       full("repo"),
+	}}}}}}
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	for idx, tc := range []struct {
+		remote  string
+		url     string
+		matches bool
+	}{
+		{"origin", "git@GitLab.com:org/team/repo.git", true},
+		{"origin", "ssh://git@gitlab.com/org/team/repo", true},
+		{"origin", "git+ssh://git@gitlab.com:22/org/team/repo.git/", true},
+		{"origin", "ssh://git@gitlab.com:2222/org/team/repo.git", false},
+		{"origin", "https://gitlab.com/org/team/repo.git", false},
+		{"origin", "git@gitlab.com:other/repo.git", false},
+		{"upstream", "git@gitlab.com:org/team/repo.git", false},
+	} {
+		repo := fmt.Sprintf("repo-%d", idx)
+		td.MustMkdirAll(repo)
+		td.MustRunGit("-C", td.FilePath(repo), "init")
+		td.MustRunGit("-C", td.FilePath(repo), "remote", "add", tc.remote, tc.url)
+		output, err := td.RunGitIdentity("-C", td.FilePath(repo), "--config", td.FilePath("config.json"), "set", "--only-auto")
+		if tc.matches {
+			require.NoError(t, err, "remote %s %s", tc.remote, tc.url)
+			require.Contains(t, string(output), "Automatically selected identity: "+identity.GetIdentifier())
+		} else {
+			require.Error(t, err, "remote %s %s", tc.remote, tc.url)
+		}
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+	td.Setenv("XDG_CACHE_HOME", td.FilePath("cache"))
+
+	identity := NewIdentityV2()
+	td.MustWriteFile("config.json", MustMarshalJSON(t, ConfigV2(identity)))
+
+	td.MustMkdirAll("repo")
+	td.MustRunGit("-C", td.FilePath("repo"), "init")
+
+	output := td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "whoami")
+	require.Empty(t, strings.TrimSpace(string(output)))
+	_, err := td.RunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "whoami", "--check")
+	require.Error(t, err)
+
+	searchQuery := []byte(identity.GetIdentifier() + "\n")
+	td.MustRunGitIdentityWithInput(searchQuery, "-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "set")
+
+	for range 2 { // second run is served from cache
+		output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "whoami", "--format", "{{.Name}}|{{.Email}}")
+		require.Empty(t, Diff(identity.GetValues()["user.name"]+"|"+identity.GetValues()["user.email"], strings.TrimSpace(string(output))))
+	}
+	require.DirExists(t, td.FilePath("cache/gitidentity")) // cache is kept in test directory
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "whoami", "--check")
+
+	td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "unset")
+	output = td.MustRunGitIdentity("-C", td.FilePath("repo"), "--config", td.FilePath("config.json"), "whoami")
+	require.Empty(t, strings.TrimSpace(string(output)))
+}
+
+func TestImportGitconfig(t *testing.T) {
+	t.Parallel()
+	td := NewTestdata(t)
+	td.Setenv("GIT_CONFIG_GLOBAL", td.FilePath("home/.gitconfig"))
+
+	identity := NewIdentityV2()
+	identity.Identifier = ""
+	oss := NewIdentityV2()
+	oss.Identifier = ""
+	td.MustMkdirAll("home/work/repo")
+	workDir, err := filepath.EvalSymlinks(td.FilePath("home/work")) // git reports resolved paths
+	require.NoError(t, err)
+	td.MustWriteFile("home/.gitconfig", []byte(strings.Join([]string{
+		"[includeIf \"gitdir:" + filepath.ToSlash(workDir) + "/\"]\n\tpath = .gitconfig-work",
+		"[includeIf \"onbranch:main\"]\n\tpath = .gitconfig-work",
+		"[includeIf \"hasconfig:remote.*.url:https://example.com/**\"]\n\tpath = .gitconfig-oss",
+		"[includeIf \"hasconfig:remote.*.url:https://*.example.org/**/repo.git\"]\n\tpath = .gitconfig-oss",
+		"[includeIf \"hasconfig:remote.*.url:https://example.net/**\"]\n\tpath = .gitconfig-missing",
+	}, "\n")+"\n"))
+	td.MustWriteFile("home/.gitconfig-work", []byte("[user]\n\tname = "+identity.GetValues()["user.name"]+"\n\temail = "+identity.GetValues()["user.email"]+"\n"))
+	td.MustWriteFile("home/.gitconfig-oss", []byte("[user]\n\tname = "+oss.GetValues()["user.name"]+"\n\temail = "+oss.GetValues()["user.email"]+"\n"))
+
+	output := td.MustRunGitIdentity("--config", td.FilePath("config.json"), "import", "--gitconfig", "--yes")
+	require.Contains(t, string(output), `skipped includeIf "onbranch:main": unsupported condition`)
+	require.Contains(t, string(output), `skipped includeIf "hasconfig:remote.*.url:https://example.net/**"`)
+
+	td.MustRunGit("-C", td.FilePath("home/work/repo"), "init")
+	td.MustRunGitIdentity("-C", td.FilePath("home/work/repo"), "--config", td.FilePath("config.json"), "set", "--only-auto")
+	outputJSON := td.MustRunGitIdentity("-C", td.FilePath("home/work/repo"), "--config", td.FilePath("config.json"), "current", "--format=json")
+	require.Empty(t, Diff(identity.GetValues(), MustUnmarshalJSON(t, outputJSON, &configv2.Identity{}).GetValues()))
+
+	for _, url := range []string{"https://example.com/org/repo", "https://git.example.org/group/subgroup/repo.git"} { // "**" matches across slashes
+		repo := "oss/" + uuid.NewString()
+		td.MustMkdirAll(repo)
+		td.MustRunGit("-C", td.FilePath(repo), "init")
+		td.MustRunGit("-C", td.FilePath(repo), "remote", "add", "origin", url)
+		td.MustRunGitIdentity("-C", td.FilePath(repo), "--config", td.FilePath("config.json"), "set", "--only-auto")
+		require.Empty(t, Diff(oss.GetValues()["user.email"], strings.TrimSpace(string(td.MustRunGit("-C", td.FilePath(repo), "config", "--local", "user.email")))))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,18 +28,44 @@ import (
 type Testdata struct {
 	t   *testing.T
 	dir string
+	env []string
 }
 
+// goToolEnv pins locations used by go tool, as they default to directories derived from HOME and XDG_CACHE_HOME, which tests redirect
+var goToolEnv = sync.OnceValues(func() ([]string, error) {
+	out, err := exec.Command("go", "env", "GOCACHE", "GOMODCACHE", "GOPATH").Output()
+	if err != nil {
+		return nil, err
+	}
+	values := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(values) != 3 {
+		return nil, fmt.Errorf("unexpected go env output: %q", out)
+	}
+	return []string{"GOCACHE=" + values[0], "GOMODCACHE=" + values[1], "GOPATH=" + values[2]}, nil
+})
+
 func NewTestdata(t *testing.T) *Testdata {
+	env, err := goToolEnv()
+	require.NoError(t, err, "cannot get go tool environment")
 	td := &Testdata{
 		t:   t,
 		dir: t.TempDir(),
+		env: slices.Clone(env),
 	}
 	t.Logf("test directory: %q", td.dir)
 
+	// configuration of user running tests must not be loaded, tests redirect these variables again as needed, as the last value is in effect
+	td.Setenv("HOME", td.FilePath("home"))
+	td.Setenv("XDG_CONFIG_HOME", td.FilePath("home/.config"))
+	td.Setenv("XDG_CONFIG_DIRS", td.FilePath("etc/xdg"))
+
 	return td
 }
 
+func (td *Testdata) Setenv(key, value string) {
+	td.env = append(td.env, key+"="+value)
+}
+
 func (td *Testdata) FilePath(names ...string) string {
 	path := td.dir
 	for _, o := range names {
@@ -63,6 +90,10 @@ func (td *Testdata) MustWriteFile(name string, data []byte) {
 	require.NoError(td.t, os.WriteFile(path, data, 0o664)) //nolint:gosec // This is jut the test and do not need to be secure
 }
 
+func (td *Testdata) MustRemove(name string) {
+	require.NoError(td.t, os.Remove(td.FilePath(name)))
+}
+
 func (td *Testdata) gitMustExists() {
 	_, err := exec.LookPath("git")
 	if err != nil {
@@ -77,6 +108,7 @@ func (td *Testdata) RunGit(args ...string) ([]byte, error) {
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), td.env...)
 	return cmd.CombinedOutput()
 }
 
@@ -98,7 +130,7 @@ func (td *Testdata) RunGitIdentity(args ...string) ([]byte, error) {
 	a := append([]string{"run", root}, args...)
 	td.t.Logf("--- go %v", a)
 	cmd := exec.CommandContext(ctx, "go", a...)
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), td.env...)
 	return cmd.CombinedOutput()
 }
 
@@ -119,7 +151,7 @@ func (td *Testdata) RunGitIdentityWithInput(input []byte, args ...string) ([]byt
 
 	a := append([]string{"run", root}, args...)
 	cmd := exec.CommandContext(ctx, "go", a...)
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), td.env...)
 	cmd.Stdin = bytes.NewBuffer(input)
 	return cmd.CombinedOutput()
 }
@@ -166,13 +198,9 @@ func ConfigV1(list ...*configv1.Identity) *configv1.Config {
 }
 
 func ConfigV2(list ...*configv2.Identity) *configv2.Config {
-	list = slices.Clone(list)
-	slices.SortFunc(list, func(i, j *configv2.Identity) int {
-		return strings.Compare(i.GetIdentifier(), j.GetIdentifier())
-	})
 	return &configv2.Config{
 		Version: "v2",
-		List:    list,
+		List:    append([]*configv2.Identity(nil), list...),
 	}
 }
 
@@ -196,3 +224,13 @@ func NewIdentityV2() *configv2.Identity {
 		},
 	}
 }
+
+func RemoteURLRule(mode configv2.ConditionMode, url string) *configv2.MatchList {
+	return &configv2.MatchList{Match: []*configv2.Match{{Subject: &configv2.Match_Remote{Remote: &configv2.MatchRemote{
+		Url: &configv2.Condition{Mode: mode, Value: url},
+	}}}}}
+}
+
+func EnvRule(name string) *configv2.MatchList {
+	return &configv2.MatchList{Match: []*configv2.Match{{Subject: &configv2.Match_Env{Env: &configv2.MatchEnv{Name: name}}}}}
+}